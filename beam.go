@@ -0,0 +1,170 @@
+package clean
+
+import (
+	"fmt"
+	"math"
+)
+
+// beamFitLevel is the fraction of the PSF peak above which pixels connected to
+// the peak are treated as the main lobe when fitting the restoring beam.
+const beamFitLevel = 0.35
+
+// fwhmPerSigma converts a Gaussian standard deviation to its full width at
+// half maximum.
+var fwhmPerSigma = 2 * math.Sqrt(2*math.Ln2)
+
+// Beam is an elliptical Gaussian restoring beam. BMaj and BMin are full widths
+// at half maximum in pixels; BPA is the position angle of the major axis in
+// degrees, measured from the +y axis towards -x.
+type Beam struct {
+	BMaj, BMin, BPA float64
+}
+
+func (b Beam) String() string {
+	return fmt.Sprintf("BMAJ=%.3f px BMIN=%.3f px BPA=%.2f deg", b.BMaj, b.BMin, b.BPA)
+}
+
+// FitBeam fits an elliptical Gaussian to the main lobe of psf. The fit is a
+// linear least-squares fit of the log of the main-lobe pixels, with the
+// Gaussian centred on and normalised to the PSF peak.
+func FitBeam(psf Image) (Beam, error) {
+	peakPos, peak := identifyMaxPosition(psf, nil, false)
+	if peak <= 0 {
+		return Beam{}, fmt.Errorf("PSF has no positive peak")
+	}
+
+	// Normal equations for ln(p/peak) = -(a dx² + b dx dy + c dy²).
+	var m [3][3]float64
+	var v [3]float64
+	for _, p := range mainLobe(psf, peakPos, beamFitLevel*peak) {
+		dx := float64(p.x - peakPos.x)
+		dy := float64(p.y - peakPos.y)
+		if dx == 0 && dy == 0 {
+			continue
+		}
+		f := [3]float64{dx * dx, dx * dy, dy * dy}
+		z := -math.Log(psf[p.x][p.y] / peak)
+		for i := range f {
+			for j := range f {
+				m[i][j] += f[i] * f[j]
+			}
+			v[i] += f[i] * z
+		}
+	}
+	coef, ok := solve3(m, v)
+	if !ok {
+		return Beam{}, fmt.Errorf("PSF main lobe is too small to fit a beam")
+	}
+
+	// The inverse covariance is [[2a, b], [b, 2c]]; its eigenvalues give the
+	// axes and the eigenvector of the smaller one the major axis.
+	a, h, c := 2*coef[0], coef[1], 2*coef[2]
+	mean := (a + c) / 2
+	diff := math.Sqrt((a-c)*(a-c)/4 + h*h)
+	lMin, lMax := mean-diff, mean+diff
+	if lMin <= 0 {
+		return Beam{}, fmt.Errorf("PSF main lobe is not an elliptical Gaussian")
+	}
+	ex, ey := h, lMin-a
+	if math.Hypot(ex, ey) < math.Abs(lMin-c) {
+		ex, ey = lMin-c, h
+	}
+
+	return Beam{
+		BMaj: fwhmPerSigma / math.Sqrt(lMin),
+		BMin: fwhmPerSigma / math.Sqrt(lMax),
+		BPA:  normalizePA(math.Atan2(-ex, ey) * 180 / math.Pi),
+	}, nil
+}
+
+// mainLobe returns the pixels connected to start whose value is at least
+// level.
+func mainLobe(psf Image, start Point, level float64) []Point {
+	seen := make(map[Point]bool)
+	stack := []Point{start}
+	var lobe []Point
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[p] || p.x < 0 || p.x >= len(psf) || p.y < 0 || p.y >= len(psf[p.x]) {
+			continue
+		}
+		seen[p] = true
+		if psf[p.x][p.y] < level {
+			continue
+		}
+		lobe = append(lobe, p)
+		stack = append(stack, Point{p.x + 1, p.y}, Point{p.x - 1, p.y}, Point{p.x, p.y + 1}, Point{p.x, p.y - 1})
+	}
+	return lobe
+}
+
+// solve3 solves the 3×3 system m·x = v by Cramer's rule.
+func solve3(m [3][3]float64, v [3]float64) ([3]float64, bool) {
+	det := func(m [3][3]float64) float64 {
+		return m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+			m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+			m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	}
+	d := det(m)
+	if math.Abs(d) < 1e-12 {
+		return [3]float64{}, false
+	}
+	var x [3]float64
+	for k := range x {
+		mk := m
+		for i := range mk {
+			mk[i][k] = v[i]
+		}
+		x[k] = det(mk) / d
+	}
+	return x, true
+}
+
+func normalizePA(pa float64) float64 {
+	for pa <= -90 {
+		pa += 180
+	}
+	for pa > 90 {
+		pa -= 180
+	}
+	if pa == 0 {
+		return 0 // drop the sign of -0
+	}
+	return pa
+}
+
+// beamKernel returns the beam as an image with unit peak, truncated at four
+// standard deviations of the major axis.
+func beamKernel(beam Beam) Image {
+	sigmaMaj := beam.BMaj / fwhmPerSigma
+	sigmaMin := beam.BMin / fwhmPerSigma
+	half := int(math.Ceil(4 * sigmaMaj))
+	pa := beam.BPA * math.Pi / 180
+	// Unit vector along the major axis, from +y towards -x.
+	mx, my := -math.Sin(pa), math.Cos(pa)
+
+	kernel := newImage(2*half+1, 2*half+1)
+	for i := range kernel {
+		for j := range kernel[i] {
+			dx := float64(i - half)
+			dy := float64(j - half)
+			u := dx*mx + dy*my
+			w := -dx*my + dy*mx
+			kernel[i][j] = math.Exp(-0.5 * (u*u/(sigmaMaj*sigmaMaj) + w*w/(sigmaMin*sigmaMin)))
+		}
+	}
+	return kernel
+}
+
+// Restore convolves model with the restoring beam and adds the residual,
+// giving an image in units of flux per beam at a uniform resolution.
+func Restore(model, residual Image, beam Beam) Image {
+	restored := convolveSame(model, beamKernel(beam))
+	for i := range restored {
+		for j := range restored[i] {
+			restored[i][j] += residual[i][j]
+		}
+	}
+	return restored
+}
@@ -0,0 +1,66 @@
+package clean
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFitBeamRotatedEllipse(t *testing.T) {
+	for _, want := range []Beam{
+		{BMaj: 6, BMin: 3, BPA: 30},
+		{BMaj: 5, BMin: 2.5, BPA: -60},
+		{BMaj: 8, BMin: 4, BPA: 75},
+		{BMaj: 4, BMin: 3, BPA: 0},
+	} {
+		psf := padCentered(beamKernel(want), 64, 64)
+		got, err := FitBeam(psf)
+		if err != nil {
+			t.Fatalf("%v: %v", want, err)
+		}
+		if math.Abs(got.BMaj-want.BMaj) > 1e-6 || math.Abs(got.BMin-want.BMin) > 1e-6 || math.Abs(got.BPA-want.BPA) > 1e-4 {
+			t.Errorf("fitted %v, want %v", got, want)
+		}
+	}
+}
+
+func TestRestorePreservesModelFlux(t *testing.T) {
+	n := 64
+	model := newImage(n, n)
+	model[20][30] = 1.5
+	model[40][25] = 0.75
+	model[33][41] = -0.25
+	modelFlux := 2.0
+	beam := Beam{BMaj: 5, BMin: 3, BPA: 40}
+
+	restored := Restore(model, newImage(n, n), beam)
+	total := 0.0
+	for _, row := range restored {
+		for _, val := range row {
+			total += val
+		}
+	}
+	// The restored image is in flux per beam, so its sum is the model flux
+	// times the beam area in pixels.
+	area := math.Pi / (4 * math.Ln2) * beam.BMaj * beam.BMin
+	if got := total / area; math.Abs(got-modelFlux) > 1e-6*modelFlux {
+		t.Errorf("restored flux is %g, model flux %g", got, modelFlux)
+	}
+}
+
+func TestDeconvolveFillsStats(t *testing.T) {
+	dirty, psf := pointSky(32, map[Point]float64{{12, 14}: 1})
+	for _, name := range Deconvolvers() {
+		d, err := NewDeconvolver(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := d.Deconvolve(dirty, psf, nil, Options{MaxIterations: 30})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := computeStatistics(result)
+		if result.Stats != want || want.Iterations != len(result.Components) || want.ModelFlux <= 0 {
+			t.Errorf("%s: stats %+v, want %+v for %d components", name, result.Stats, want, len(result.Components))
+		}
+	}
+}
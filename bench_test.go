@@ -0,0 +1,84 @@
+package clean
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mothergoose31/clean/internal/testutil"
+)
+
+// benchSizes are the image sizes the pipeline benchmarks run at.
+var benchSizes = []int{64, 128, 256, 512}
+
+const testACB = "E18A24.0.bin0000.source0000.acb"
+
+func BenchmarkParseACB(b *testing.B) {
+	testutil.Quiet(b)
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseACB(testACB); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCreateDirtyMapsFromACB(b *testing.B) {
+	testutil.Quiet(b)
+	data, err := ParseACB(testACB)
+	if err != nil {
+		b.Fatal(err)
+	}
+	pool := newWorkerPool(DefaultOptions())
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				dirtyMaps(pool, data, defaultNumScales, n)
+			}
+		})
+	}
+}
+
+func BenchmarkCreatePSFFromACB(b *testing.B) {
+	testutil.Quiet(b)
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				createPSFFromACB(n)
+			}
+		})
+	}
+}
+
+// BenchmarkClean runs each deconvolver for a fixed number of iterations on
+// the BL Lac data.
+func BenchmarkClean(b *testing.B) {
+	testutil.Quiet(b)
+	data, err := ParseACB(testACB)
+	if err != nil {
+		b.Fatal(err)
+	}
+	pool := newWorkerPool(DefaultOptions())
+	for _, n := range benchSizes {
+		maps, err := dirtyMaps(pool, data, defaultNumScales, n)
+		if err != nil {
+			b.Fatal(err)
+		}
+		dirty := sumMaps(maps)
+		psf := createPSFFromACB(n)
+		for _, name := range Deconvolvers() {
+			d, err := NewDeconvolver(name)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.Run(fmt.Sprintf("%s/%d", name, n), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := d.Deconvolve(dirty, psf, nil, Options{MaxIterations: 50}); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
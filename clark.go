@@ -0,0 +1,206 @@
+package clean
+
+import (
+	"fmt"
+	"math"
+)
+
+// clarkPatchSize is the half-width in pixels of the beam patch used to update
+// active pixels during a Clark minor cycle.
+const clarkPatchSize = 16
+
+// ClarkCleaner implements Clark (1980) CLEAN. Minor cycles run Hogbom CLEAN
+// on the few pixels brighter than the largest PSF sidelobe outside a small
+// beam patch; each major cycle then subtracts the accumulated components from
+// the whole residual with the PSF cropped to its support.
+type ClarkCleaner struct {
+	imageSize   int
+	opts        Options
+	psf         Image
+	psfPeak     float64
+	footprint   Image
+	maxSidelobe float64
+}
+
+type activePixel struct {
+	pos Point
+	val float64
+}
+
+// NewClarkCleaner returns a Clark cleaner configured by opts, with zero fields
+// taken from DefaultOptions. Scale settings are ignored.
+func NewClarkCleaner(opts Options) (*ClarkCleaner, error) {
+	resolved, err := opts.resolve(DefaultOptions())
+	if err != nil {
+		return nil, err
+	}
+	return &ClarkCleaner{opts: resolved}, nil
+}
+
+// Deconvolve runs Clark CLEAN on dirty with the given PSF. Non-zero fields of
+// opts, and those listed in opts.Set, override the options the cleaner was
+// constructed with.
+func (cc *ClarkCleaner) Deconvolve(dirty, psf Image, mask Mask, opts Options) (*CleanResult, error) {
+	resolved, err := opts.resolve(cc.opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkInputs(dirty, psf, mask, resolved.StartModel); err != nil {
+		return nil, err
+	}
+	run := *cc
+	run.opts = resolved
+	run.imageSize = len(dirty)
+	run.setPSF(psf)
+
+	return run.clean(dirty, mask), nil
+}
+
+func (cc *ClarkCleaner) setPSF(psf Image) {
+	n := cc.imageSize
+	cc.psf = padCentered(psf, 2*n-1, 2*n-1)
+	cc.psfPeak = cc.psf[n-1][n-1]
+	cc.footprint = cropImageSupport(cc.psf, cc.opts.SupportCutoff)
+
+	cc.maxSidelobe = 0
+	for i := range cc.psf {
+		for j := range cc.psf[i] {
+			if abs(i-(n-1)) <= clarkPatchSize && abs(j-(n-1)) <= clarkPatchSize {
+				continue
+			}
+			cc.maxSidelobe = math.Max(cc.maxSidelobe, math.Abs(cc.psf[i][j]/cc.psfPeak))
+		}
+	}
+}
+
+func (cc *ClarkCleaner) clean(dirty Image, mask Mask) *CleanResult {
+	fmt.Println("Starting Clark CLEAN algorithm...")
+	model, grid := startModel(dirty, cc.psf, cc.opts.StartModel)
+	// residual aliases grid, which the stopping criteria measure.
+	residual := grid.Image()
+	components := append([]Component(nil), cc.opts.StartModel...)
+	stop := StopMaxIterations
+	c := cc.imageSize - 1
+
+	iterCount := 0
+	majorCount := 0
+	finished := false
+	st := newStopper(cc.opts, grid)
+	window := activeMask(cc.opts, nil, mask, grid, st.noise)
+	for !finished && iterCount < cc.opts.MaxIterations {
+		_, maxIntensity := identifyMaxPosition(residual, window, cc.opts.AbsolutePeak)
+		fmt.Printf("Major cycle %d: peak %f\n", majorCount+1, maxIntensity)
+		if reason, done := st.beforeComponent(maxIntensity); done {
+			stop = reason
+			break
+		}
+
+		limit := math.Max(cc.opts.Threshold, math.Abs(maxIntensity)*cc.maxSidelobe)
+		active := cc.selectActive(residual, window, limit)
+		fmt.Printf("  %d active pixels above %f\n", len(active), limit)
+
+		var cycle []Component
+		for len(active) > 0 && iterCount < cc.opts.MaxIterations {
+			idx := cc.activePeak(active)
+			peak := active[idx]
+			if cc.key(peak.val) < limit {
+				break
+			}
+			if reason, done := st.beforeComponent(peak.val); done {
+				stop, finished = reason, true
+				break
+			}
+			flux := cc.opts.Gain * peak.val / cc.psfPeak
+			iterCount++
+			cycle = append(cycle, Component{X: peak.pos.x, Y: peak.pos.y, Flux: flux, Iteration: iterCount})
+			model[peak.pos.x][peak.pos.y] += flux
+			for k := range active {
+				dx := active[k].pos.x - peak.pos.x
+				dy := active[k].pos.y - peak.pos.y
+				if abs(dx) <= clarkPatchSize && abs(dy) <= clarkPatchSize {
+					active[k].val -= flux * cc.psf[c+dx][c+dy]
+				}
+			}
+			if reason, done := st.afterComponent(flux); done {
+				stop, finished = reason, true
+				break
+			}
+		}
+		if len(cycle) == 0 {
+			if !finished {
+				stop = StopThreshold
+			}
+			break
+		}
+
+		fmt.Printf("  Subtracting %d components...\n", len(cycle))
+		for _, comp := range cycle {
+			subtractShifted(residual, cc.footprint, Point{x: comp.X, y: comp.Y}, comp.Flux)
+		}
+		components = append(components, cycle...)
+		majorCount++
+		if finished {
+			break
+		}
+		if reason, done := measure(st, grid); done {
+			stop = reason
+			break
+		}
+		window = activeMask(cc.opts, window, mask, grid, st.noise)
+	}
+	fmt.Printf("  Stopping: %v\n", stop)
+	fmt.Printf("Clark CLEAN completed in %d iterations over %d major cycles\n", iterCount, majorCount)
+
+	result := &CleanResult{
+		Model:      model,
+		Residual:   residual,
+		Components: components,
+		StopReason: stop,
+	}
+	result.Stats = computeStatistics(result)
+	return result
+}
+
+// selectActive returns the residual pixels eligible for the next minor cycle.
+func (cc *ClarkCleaner) selectActive(residual Image, mask Mask, limit float64) []activePixel {
+	var active []activePixel
+	for i, row := range residual {
+		for j, val := range row {
+			if !mask.allows(i, j) {
+				continue
+			}
+			if cc.key(val) >= limit {
+				active = append(active, activePixel{pos: Point{x: i, y: j}, val: val})
+			}
+		}
+	}
+	return active
+}
+
+func (cc *ClarkCleaner) activePeak(active []activePixel) int {
+	best := 0
+	bestKey := math.Inf(-1)
+	for k, p := range active {
+		if key := cc.key(p.val); key > bestKey {
+			bestKey = key
+			best = k
+		}
+	}
+	return best
+}
+
+// key returns the value peaks are ranked by: val itself, or its magnitude
+// when searching on absolute value.
+func (cc *ClarkCleaner) key(val float64) float64 {
+	if cc.opts.AbsolutePeak {
+		return math.Abs(val)
+	}
+	return val
+}
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
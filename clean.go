@@ -2,13 +2,13 @@ package clean
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"math"
 	"os"
-	"runtime"
 	"strconv"
 	"strings"
-	"sync"
 )
 
 type Point struct {
@@ -19,7 +19,12 @@ type Image [][]float64
 type PFS []Image
 
 const (
-	gainFactor = 0.1
+	// maxACBLineLength is the longest line ReadACB accepts. Real ACB lines
+	// are under 100 bytes.
+	maxACBLineLength = 4096
+	// maxACBRecords bounds the frequencies, polarizations and amplitudes
+	// ReadACB keeps, so a corrupt file cannot exhaust memory.
+	maxACBRecords = 1 << 20
 )
 
 type ACBData struct {
@@ -34,45 +39,17 @@ type ACBData struct {
 }
 
 type MultiScaleCleaner struct {
-	numScales     int
-	imageSize     int
-	gainFactor    float64
-	maxIterations int
-	threshold     float64
-	scaleBias     []float64
-	psfs          PFS
-	basisFuncs    PFS
-	pool          *workerPool
-}
-
-type workerPool struct {
-	workers int
-	wg      sync.WaitGroup
-}
-
-func newWorkerPool() *workerPool {
-	return &workerPool{
-		workers: (runtime.NumCPU() * 3) / 4,
-	}
-}
-
-func (p *workerPool) divide(total int) [][2]int {
-	chunks := make([][2]int, p.workers)
-	chunkSize := total / p.workers
-	remainder := total % p.workers
-
-	start := 0
-	for i := 0; i < p.workers; i++ {
-		size := chunkSize
-		if i < remainder {
-			size++
-		}
-		chunks[i] = [2]int{start, start + size}
-		start += size
-	}
-	return chunks
+	imageSize  int
+	opts       Options
+	scaleSizes []float64
+	scaleBias  []float64
+	psf        Image
+	basisFuncs PFS
+	crossPeaks []float64
+	pool       *workerPool
 }
 
+// ParseACB reads the ACB file at filename.
 func ParseACB(filename string) (*ACBData, error) {
 	fmt.Println("Parsing ACB file...")
 	file, err := os.Open(filename)
@@ -81,13 +58,22 @@ func ParseACB(filename string) (*ACBData, error) {
 	}
 	defer file.Close()
 
+	return ReadACB(file)
+}
+
+// ReadACB parses ACB data from r. The first line must be the timerange
+// header. Lines longer than maxACBLineLength, more than maxACBRecords
+// frequencies or amplitudes, and values that are not finite numbers are
+// rejected with an error naming the line.
+func ReadACB(r io.Reader) (*ACBData, error) {
 	data := &ACBData{
 		Frequencies:   []float64{},
 		Polarizations: []string{},
 		Amplitudes:    []float64{},
 	}
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 256), maxACBLineLength)
 	lineNum := 0
 
 	for scanner.Scan() {
@@ -96,6 +82,9 @@ func ParseACB(filename string) (*ACBData, error) {
 
 		if lineNum == 1 {
 			parts := strings.Fields(line)
+			if len(parts) == 0 || parts[0] != "timerange:" {
+				return nil, fmt.Errorf("line 1: missing timerange header")
+			}
 			for i, part := range parts {
 				if part == "timerange:" && i+4 < len(parts) {
 					data.TimeRange = strings.Join(parts[i+1:i+5], " ")
@@ -118,246 +107,487 @@ func ParseACB(filename string) (*ACBData, error) {
 			parts := strings.Fields(line)
 			for i, part := range parts {
 				if part == "bandfreq:" && i+2 < len(parts) {
-					freq, err := strconv.ParseFloat(parts[i+1], 64)
-					if err == nil {
-						data.Frequencies = append(data.Frequencies, freq)
+					if len(data.Frequencies) == maxACBRecords {
+						return nil, fmt.Errorf("line %d: more than %d frequencies", lineNum, maxACBRecords)
+					}
+					freq, err := parseFinite(parts[i+1])
+					if err != nil {
+						return nil, fmt.Errorf("line %d: frequency: %v", lineNum, err)
 					}
+					data.Frequencies = append(data.Frequencies, freq)
 				} else if part == "polar:" && i+1 < len(parts) {
+					if len(data.Polarizations) == maxACBRecords {
+						return nil, fmt.Errorf("line %d: more than %d polarizations", lineNum, maxACBRecords)
+					}
 					data.Polarizations = append(data.Polarizations, parts[i+1])
 				}
 			}
 		} else if strings.HasPrefix(line, " 1 LM") {
 			parts := strings.Fields(line)
-			if len(parts) >= 4 {
-				amp, err := strconv.ParseFloat(parts[3], 64)
-				if err == nil {
-					data.Amplitudes = append(data.Amplitudes, amp)
-				}
+			if len(parts) < 4 {
+				return nil, fmt.Errorf("line %d: amplitude record has %d fields, want 4", lineNum, len(parts))
+			}
+			if len(data.Amplitudes) == maxACBRecords {
+				return nil, fmt.Errorf("line %d: more than %d amplitudes", lineNum, maxACBRecords)
 			}
+			amp, err := parseFinite(parts[3])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: amplitude: %v", lineNum, err)
+			}
+			data.Amplitudes = append(data.Amplitudes, amp)
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, fmt.Errorf("line %d: longer than %d bytes", lineNum+1, maxACBLineLength)
+		}
 		return nil, fmt.Errorf("error scanning ACB file: %v", err)
 	}
+	if lineNum == 0 {
+		return nil, fmt.Errorf("empty ACB file")
+	}
 
 	return data, nil
 }
 
-func NewMultiScaleCleaner(numScales, imageSize int, threshold float64, maxIterations int) *MultiScaleCleaner {
-	scaleBias := make([]float64, numScales)
-	for i := range scaleBias {
-		scaleBias[i] = 1.0 / math.Sqrt(float64(i+1))
+// parseFinite parses a number, which must be finite.
+func parseFinite(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad value %q", s)
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("value %q is not finite", s)
 	}
+	return v, nil
+}
 
-	return &MultiScaleCleaner{
-		numScales:     numScales,
-		imageSize:     imageSize,
-		gainFactor:    gainFactor,
-		maxIterations: maxIterations,
-		threshold:     threshold,
-		scaleBias:     scaleBias,
-		pool:          newWorkerPool(),
+// NewMultiScaleCleaner returns a multi-scale cleaner configured by opts, with
+// zero fields taken from DefaultOptions.
+func NewMultiScaleCleaner(opts Options) (*MultiScaleCleaner, error) {
+	msc := &MultiScaleCleaner{
+		opts: DefaultOptions(),
+	}
+	if err := msc.configure(opts); err != nil {
+		return nil, err
 	}
+	return msc, nil
 }
 
-func CleanACB(filename string, numScales int, imageSize int) (Image, error) {
-	data, err := ParseACB(filename)
+func (msc *MultiScaleCleaner) configure(opts Options) error {
+	resolved, err := opts.resolve(msc.opts)
 	if err != nil {
+		return err
+	}
+	msc.opts = resolved
+	msc.pool = resolved.workerPool()
+	msc.scaleSizes = resolved.scaleSizes()
+	if len(msc.scaleSizes) == 0 {
+		return fmt.Errorf("multi-scale CLEAN needs at least one scale")
+	}
+	msc.scaleBias = resolved.ScaleBias(msc.scaleSizes)
+	if len(msc.scaleBias) != len(msc.scaleSizes) {
+		return fmt.Errorf("scale bias has %d weights for %d scales", len(msc.scaleBias), len(msc.scaleSizes))
+	}
+	return nil
+}
+
+// Deconvolve runs multi-scale CLEAN on dirty with the given PSF. Non-zero
+// fields of opts, and those listed in opts.Set, override the options the
+// cleaner was constructed with.
+func (msc *MultiScaleCleaner) Deconvolve(dirty, psf Image, mask Mask, opts Options) (*CleanResult, error) {
+	run := *msc
+	if err := run.configure(opts); err != nil {
+		return nil, err
+	}
+	if err := checkInputs(dirty, psf, mask, run.opts.StartModel); err != nil {
 		return nil, err
 	}
+	run.imageSize = len(dirty)
+	run.setPSF(psf)
+	run.pool.start()
+	defer run.pool.stop()
 
-	cleaner := NewMultiScaleCleaner(numScales, imageSize, 1e-5, 50)
-	cleaner.psfs = createPSFsFromACB(numScales, imageSize)
-	cleaner.basisFuncs = createBasisFunctionsFromACB(numScales, imageSize)
+	if run.opts.Float32 {
+		return runMultiScale[float32](&run, dirty, mask)
+	}
+	return runMultiScale[float64](&run, dirty, mask)
+}
 
-	dirtyMaps := createDirtyMapsFromACB(data, numScales, imageSize)
-	cleanedImage := cleaner.Clean(dirtyMaps)
+func runMultiScale[T Float](msc *MultiScaleCleaner, dirty Image, mask Mask) (*CleanResult, error) {
+	beams, err := multiScaleBeams[T](msc)
+	if err != nil {
+		return nil, err
+	}
+	return cleanMultiScale(msc, beams, dirty, mask), nil
+}
 
-	return cleanedImage, nil
+// CleanACB deconvolves the data in an ACB file, placing components only
+// inside mask (nil for no mask), and returns the restored result.
+func CleanACB(filename string, algorithm string, imageSize int, mask Mask, opts Options) (*CleanResult, error) {
+	deconvolver, err := NewDeconvolver(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ParseACB(filename)
+	if err != nil {
+		return nil, err
+	}
+	return cleanACBData(deconvolver, data, imageSize, mask, opts)
 }
 
-func (msc *MultiScaleCleaner) Clean(unclean PFS) Image {
-	fmt.Println("Starting Multi-scale CLEAN algorithm...")
-	numScales := len(unclean)
-	cleanComponents := make(Image, len(unclean[0]))
-	for i := range cleanComponents {
-		cleanComponents[i] = make([]float64, len(unclean[0][i]))
-	}
-	currentDirtyMaps := make([]Image, numScales)
-	for i := range currentDirtyMaps {
-		currentDirtyMaps[i] = make(Image, len(unclean[i]))
-		for j := range currentDirtyMaps[i] {
-			currentDirtyMaps[i][j] = make([]float64, len(unclean[i][j]))
-			copy(currentDirtyMaps[i][j], unclean[i][j])
-		}
+// CleanACBData is CleanACB for data already read with ParseACB or ReadACB.
+func CleanACBData(data *ACBData, algorithm string, imageSize int, mask Mask, opts Options) (*CleanResult, error) {
+	deconvolver, err := NewDeconvolver(algorithm)
+	if err != nil {
+		return nil, err
 	}
+	return cleanACBData(deconvolver, data, imageSize, mask, opts)
+}
 
-	iterCount := 0
-	fmt.Println("Beginning iterations...")
+func cleanACBData(deconvolver Deconvolver, data *ACBData, imageSize int, mask Mask, opts Options) (*CleanResult, error) {
+	resolved, err := opts.resolve(DefaultOptions())
+	if err != nil {
+		return nil, err
+	}
+	// The dirty maps and the deconvolver share one pool.
+	opts.pool = resolved.workerPool()
+	psf := createPSFFromACB(imageSize)
+	maps, err := dirtyMaps(opts.pool, data, len(resolved.scaleSizes()), imageSize)
+	if err != nil {
+		return nil, err
+	}
+	return cleanImage(deconvolver, sumMaps(maps), psf, mask, opts)
+}
 
-	for iterCount < msc.maxIterations {
-		fmt.Printf("Iteration %d/%d...\n", iterCount+1, msc.maxIterations)
-		rescaledDirtyMaps := msc.rescaleDirtyMaps(currentDirtyMaps)
-		maxScale := msc.identifyMaxScale(rescaledDirtyMaps)
-		fmt.Printf("  Selected scale: %d\n", maxScale)
-		maxPos, maxIntensity := identifyMaxPosition(currentDirtyMaps[maxScale])
-		fmt.Printf("  Max position: (%d, %d), intensity: %f\n", maxPos.x, maxPos.y, maxIntensity)
-		if maxIntensity < msc.threshold {
-			fmt.Println("  Maximum intensity below threshold, stopping.")
-			break
-		}
-		fmt.Println("  Updating clean components...")
-		msc.updateCleanComponents(cleanComponents, msc.basisFuncs[maxScale], maxPos, maxIntensity, msc.psfs[maxScale])
-		fmt.Println("  Updating dirty maps...")
-		msc.updateDirtyMaps(currentDirtyMaps, msc.basisFuncs[maxScale], maxPos, maxIntensity)
-		if msc.stoppingCondition(currentDirtyMaps) {
-			fmt.Println("  Stopping condition met, ending iterations.")
-			break
-		}
-		iterCount++
+// CleanImage deconvolves dirty with psf, both square images of the same size
+// with the PSF peak at the centre pixel, and restores the result with
+// opts.Beam, or with a beam fitted to psf if that is unset. The result's WCS
+// is opts.WCS, or NewWCS with a 1 mas cell if that is unset.
+func CleanImage(dirty, psf Image, algorithm string, mask Mask, opts Options) (*CleanResult, error) {
+	deconvolver, err := NewDeconvolver(algorithm)
+	if err != nil {
+		return nil, err
 	}
-	fmt.Printf("Multi-scale CLEAN completed in %d iterations\n", iterCount)
-	fmt.Println("Adding residuals...")
-	cleanedImage := msc.addResiduals(cleanComponents, currentDirtyMaps)
-	return cleanedImage
+	return cleanImage(deconvolver, dirty, psf, mask, opts)
 }
 
-func (msc *MultiScaleCleaner) rescaleDirtyMaps(dirtyMaps []Image) []Image {
-	rescaled := make([]Image, len(dirtyMaps))
-	for i := range rescaled {
-		rescaled[i] = make(Image, len(dirtyMaps[i]))
-		for j := range rescaled[i] {
-			rescaled[i][j] = make([]float64, len(dirtyMaps[i][j]))
-			for k := range rescaled[i][j] {
-				rescaled[i][j][k] = msc.scaleBias[i] * dirtyMaps[i][j][k]
-			}
+func cleanImage(deconvolver Deconvolver, dirty, psf Image, mask Mask, opts Options) (*CleanResult, error) {
+	if err := checkInputs(dirty, psf, mask, opts.StartModel); err != nil {
+		return nil, err
+	}
+
+	beam := opts.Beam
+	if beam.BMaj <= 0 {
+		var err error
+		fmt.Println("Fitting restoring beam to PSF...")
+		beam, err = FitBeam(psf)
+		if err != nil {
+			return nil, err
 		}
 	}
-	return rescaled
+
+	result, err := deconvolver.Deconvolve(dirty, psf, mask, opts)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Restoring with beam %v...\n", beam)
+	result.Restore(beam)
+	result.WCS = opts.WCS
+	if result.WCS.Cell == 0 {
+		result.WCS = NewWCS(len(dirty), len(dirty), Milliarcsecond)
+	}
+
+	return result, nil
 }
 
-func (msc *MultiScaleCleaner) identifyMaxScale(rescaledDirtyMaps []Image) int {
-	maxScale := 0
-	maxIntensity := math.Inf(-1)
-
-	for i, img := range rescaledDirtyMaps {
-		for _, row := range img {
-			for _, val := range row {
-				if val > maxIntensity {
-					maxIntensity = val
-					maxScale = i
-				}
+// setPSF installs the dirty beam and the scale kernels B_s. Each kernel is
+// cropped to its support before the next is built.
+func (msc *MultiScaleCleaner) setPSF(psf Image) {
+	msc.psf = psf
+	msc.basisFuncs = make(PFS, len(msc.scaleSizes))
+	for s, size := range msc.scaleSizes {
+		b := createBasisFunctionsFromACB([]float64{size}, msc.imageSize)[0]
+		msc.basisFuncs[s] = cropImageSupport(b, msc.opts.SupportCutoff)
+	}
+}
+
+// scaleBeams holds the beams the minor cycle subtracts, stored in precision
+// T: the per-scale beams B_s*PSF used to update the unsmoothed residual, and
+// the scale-pair beams B_s*B_t*PSF used to update each smoothed residual
+// (Cornwell 2008, eq. 6).
+type scaleBeams[T Float] struct {
+	scale []GridOf[T]
+	cross [][]GridOf[T]
+}
+
+// multiScaleBeams precomputes the beams for the PSF installed in msc and
+// records the peak of each B_s*B_s*PSF in msc.crossPeaks. The convolutions
+// are done in double precision, but each task builds one scale PSF B_t*PSF
+// and its cross beams with the smaller scales, stores them in T cropped to
+// their support, and drops the double-precision maps before the next task.
+// Spectra are recomputed for each convolution rather than cached: at 2048²
+// a padded spectrum takes a gigabyte.
+func multiScaleBeams[T Float](msc *MultiScaleCleaner) (*scaleBeams[T], error) {
+	fmt.Println("Precomputing scale-scale cross PSFs...")
+	n := msc.imageSize
+	padded := padCentered(msc.psf, 2*n-1, 2*n-1)
+	numScales := len(msc.scaleSizes)
+	beams := &scaleBeams[T]{
+		scale: make([]GridOf[T], numScales),
+		cross: make([][]GridOf[T], numScales),
+	}
+	for s := range beams.cross {
+		beams.cross[s] = make([]GridOf[T], numScales)
+	}
+	msc.crossPeaks = make([]float64, numScales)
+	// The largest scale has the most cross beams, so it is started first.
+	err := msc.pool.run("scale PSFs", numScales, func(i int) {
+		t := numScales - 1 - i
+		g := convolveGrid(padded, msc.basisFuncs[t], true)
+		beams.scale[t] = convertGrid[T](cropSupport(g, msc.opts.SupportCutoff))
+		scalePSF := g.Image()
+		for s := 0; s <= t; s++ {
+			g := convolveGrid(scalePSF, msc.basisFuncs[s], true)
+			if s == t {
+				msc.crossPeaks[s] = g.At(n-1, n-1)
 			}
+			beams.cross[s][t] = convertGrid[T](cropSupport(g, msc.opts.SupportCutoff))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for s := 0; s < numScales; s++ {
+		for t := 0; t < s; t++ {
+			beams.cross[s][t] = beams.cross[t][s]
 		}
 	}
+	return beams, nil
+}
 
-	return maxScale
+// msWorkspace holds the maps of one multi-scale run. Everything the minor
+// cycle touches is allocated here once and the pool's workers run for the
+// whole run, so iterating does not allocate.
+type msWorkspace[T Float] struct {
+	msc      *MultiScaleCleaner
+	beams    *scaleBeams[T]
+	smoothed []GridOf[T]
+	residual GridOf[T]
+	// peaks holds the peak of each smoothed residual inside mask, found by
+	// the task that last updated that residual.
+	peaks []scalePeak
+	mask  Mask
+	// maskCount is the number of pixels mask allowed when the peaks were
+	// last found, or -1 before the first search.
+	maskCount int
+	// scale, pos and amplitude describe the component being subtracted.
+	scale     int
+	pos       Point
+	amplitude T
+	// update and scan are the pool tasks, bound once to avoid allocating a
+	// closure per iteration.
+	update, scan func(t int)
 }
 
-func (msc *MultiScaleCleaner) updateCleanComponents(cleanComponents Image, basisFunction Image, maxPos Point, maxIntensity float64, psf Image) {
-	normFactor := maxIntensity / maxValue(convolve(basisFunction, psf))
-	for i := range basisFunction {
-		for j := range basisFunction[i] {
-			x := maxPos.x + i - len(basisFunction)/2
-			y := maxPos.y + j - len(basisFunction[i])/2
-			if x >= 0 && x < len(cleanComponents) && y >= 0 && y < len(cleanComponents[x]) {
-				cleanComponents[x][y] += msc.gainFactor * normFactor * basisFunction[i][j]
-			}
+type scalePeak struct {
+	pos Point
+	val float64
+}
+
+// newMSWorkspace smooths residual with every scale. The maps are held only
+// in precision T, so for float32 the double-precision residual is not kept.
+func newMSWorkspace[T Float](msc *MultiScaleCleaner, beams *scaleBeams[T], residual Grid) *msWorkspace[T] {
+	ws := &msWorkspace[T]{
+		msc:       msc,
+		beams:     beams,
+		smoothed:  make([]GridOf[T], len(msc.scaleSizes)),
+		residual:  convertGrid[T](residual),
+		peaks:     make([]scalePeak, len(msc.scaleSizes)),
+		maskCount: -1,
+	}
+	ws.update = ws.updateTask
+	ws.scan = ws.scanTask
+	img := residual.Image()
+	msc.pool.runAll("smooth", len(ws.smoothed), func(s int) {
+		ws.smoothed[s] = convertGrid[T](convolveGrid(img, msc.basisFuncs[s], true))
+	})
+	return ws
+}
+
+// setMask restricts components to mask and finds the peak of every smoothed
+// residual inside it. The search is skipped when mask is the mask already set
+// and allows as many pixels: the user mask is passed unchanged, and an
+// auto-mask only grows in place.
+func (ws *msWorkspace[T]) setMask(mask Mask) {
+	count := mask.Count()
+	if count == ws.maskCount && sameMask(mask, ws.mask) {
+		return
+	}
+	ws.mask, ws.maskCount = mask, count
+	ws.msc.pool.runAll("find peaks", len(ws.smoothed), ws.scan)
+}
+
+func (ws *msWorkspace[T]) scanTask(t int) {
+	ws.peaks[t].pos, ws.peaks[t].val = gridPeak(ws.smoothed[t], ws.mask, ws.msc.opts.AbsolutePeak)
+}
+
+// identifyMaxScale returns the scale whose biased smoothed residual peaks
+// highest, together with the position and unbiased, signed value of that peak.
+func (ws *msWorkspace[T]) identifyMaxScale() (int, Point, float64) {
+	maxScale := 0
+	maxBiased := math.Inf(-1)
+	for s, p := range ws.peaks {
+		biased := ws.msc.scaleBias[s] * p.val
+		if ws.msc.opts.AbsolutePeak {
+			biased = math.Abs(biased)
+		}
+		if biased > maxBiased {
+			maxBiased = biased
+			maxScale = s
 		}
 	}
+	return maxScale, ws.peaks[maxScale].pos, ws.peaks[maxScale].val
 }
 
-func (msc *MultiScaleCleaner) updateDirtyMaps(dirtyMaps []Image, basisFunction Image, maxPos Point, maxIntensity float64) {
-	pool := msc.pool
-	var wg sync.WaitGroup
-	crossConvs := make([]Image, len(dirtyMaps))
-	wg.Add(len(dirtyMaps))
-	for i := range dirtyMaps {
-		go func(idx int) {
-			defer wg.Done()
-			crossConvs[idx] = convolve(basisFunction, msc.psfs[idx])
-		}(i)
-	}
-	wg.Wait()
-	chunks := pool.divide(len(dirtyMaps))
-	pool.wg.Add(len(chunks))
-	for _, chunk := range chunks {
-		go func(start, end int) {
-			defer pool.wg.Done()
-
-			for i := start; i < end; i++ {
-				crossConv := crossConvs[i]
-				normFactor := msc.gainFactor * maxIntensity / maxValue(crossConv)
-
-				blockSize := 32
-				for j := 0; j < len(crossConv); j += blockSize {
-					for k := 0; k < len(crossConv[0]); k += blockSize {
-						endJ := min(j+blockSize, len(crossConv))
-						endK := min(k+blockSize, len(crossConv[0]))
-
-						for jj := j; jj < endJ; jj++ {
-							for kk := k; kk < endK; kk++ {
-								x := maxPos.x + jj - len(crossConv)/2
-								y := maxPos.y + kk - len(crossConv[0])/2
-								if x >= 0 && x < len(dirtyMaps[i]) && y >= 0 && y < len(dirtyMaps[i][x]) {
-									dirtyMaps[i][x][y] -= normFactor * crossConv[jj][kk]
-								}
-							}
-						}
-					}
-				}
-			}
-		}(chunk[0], chunk[1])
+// updateDirtyMaps subtracts a component of the given scale and amplitude from
+// every smoothed residual using the precomputed cross PSFs, and from the
+// unsmoothed residual using the scale PSF. Each smoothed residual is scanned
+// for its new peak by the same task, so the maps are read once per iteration.
+func (ws *msWorkspace[T]) updateDirtyMaps(scale int, pos Point, amplitude float64) {
+	ws.scale, ws.pos, ws.amplitude = scale, pos, T(amplitude)
+	ws.msc.pool.runAll("update residuals", len(ws.smoothed)+1, ws.update)
+}
+
+func (ws *msWorkspace[T]) updateTask(t int) {
+	if t == len(ws.smoothed) {
+		ws.residual.AddShifted(ws.beams.scale[ws.scale], ws.pos.x, ws.pos.y, -ws.amplitude)
+		return
 	}
-	pool.wg.Wait()
+	ws.smoothed[t].AddShifted(ws.beams.cross[ws.scale][t], ws.pos.x, ws.pos.y, -ws.amplitude)
+	ws.scanTask(t)
 }
 
-func (msc *MultiScaleCleaner) stoppingCondition(dirtyMaps []Image) bool {
-	for _, img := range dirtyMaps {
-		for _, row := range img {
-			for _, val := range row {
-				if math.Abs(val) > msc.threshold {
-					return false
-				}
+// cleanMultiScale runs the multi-scale minor cycle with the residuals held in
+// precision T.
+func cleanMultiScale[T Float](msc *MultiScaleCleaner, beams *scaleBeams[T], dirty Image, mask Mask) *CleanResult {
+	fmt.Println("Starting Multi-scale CLEAN algorithm...")
+	n := len(dirty)
+	model, residual := startModel(dirty, padCentered(msc.psf, 2*n-1, 2*n-1), msc.opts.StartModel)
+	components := make([]Component, 0, len(msc.opts.StartModel)+msc.opts.MaxIterations)
+	components = append(components, msc.opts.StartModel...)
+	stop := StopMaxIterations
+
+	fmt.Println("Smoothing dirty image with each scale...")
+	ws := newMSWorkspace(msc, beams, residual)
+
+	iterCount := 0
+	st := newStopper(msc.opts, ws.residual)
+	active := activeMask(msc.opts, nil, mask, ws.residual, st.noise)
+	ws.setMask(active)
+	fmt.Println("Beginning iterations...")
+
+	for iterCount < msc.opts.MaxIterations {
+		fmt.Printf("Iteration %d/%d...\n", iterCount+1, msc.opts.MaxIterations)
+		maxScale, maxPos, maxIntensity := ws.identifyMaxScale()
+		fmt.Printf("  Selected scale: %d\n", maxScale)
+		fmt.Printf("  Max position: (%d, %d), intensity: %f\n", maxPos.x, maxPos.y, maxIntensity)
+		if reason, done := st.beforeComponent(maxIntensity); done {
+			stop = reason
+			break
+		}
+		amplitude := msc.opts.Gain * maxIntensity / msc.crossPeaks[maxScale]
+		iterCount++
+		fmt.Println("  Updating clean components...")
+		addShifted(model, msc.basisFuncs[maxScale], maxPos, amplitude)
+		components = append(components, Component{
+			X:         maxPos.x,
+			Y:         maxPos.y,
+			Scale:     maxScale,
+			Size:      msc.scaleSizes[maxScale],
+			Flux:      amplitude,
+			Iteration: iterCount,
+		})
+		fmt.Println("  Updating dirty maps...")
+		ws.updateDirtyMaps(maxScale, maxPos, amplitude)
+		if reason, done := st.afterComponent(amplitude); done {
+			stop = reason
+			break
+		}
+		if iterCount%measureInterval == 0 {
+			if reason, done := measure(st, ws.residual); done {
+				stop = reason
+				break
 			}
+			active = activeMask(msc.opts, active, mask, ws.residual, st.noise)
+			ws.setMask(active)
 		}
 	}
-	return true
-}
+	fmt.Printf("  Stopping: %v\n", stop)
+	fmt.Printf("Multi-scale CLEAN completed in %d iterations\n", iterCount)
 
-func (msc *MultiScaleCleaner) addResiduals(cleanComponents Image, dirtyMaps []Image) Image {
-	residualMap := make(Image, len(dirtyMaps[0]))
-	for i := range residualMap {
-		residualMap[i] = make([]float64, len(dirtyMaps[0][i]))
+	scaleResiduals := make(PFS, len(ws.smoothed))
+	for s, g := range ws.smoothed {
+		scaleResiduals[s] = g.Image()
 	}
+	result := &CleanResult{
+		Model:          model,
+		Residual:       ws.residual.Image(),
+		ScaleResiduals: scaleResiduals,
+		Components:     components,
+		StopReason:     stop,
+	}
+	result.Stats = computeStatistics(result)
+	return result
+}
 
-	for _, img := range dirtyMaps {
-		for i := range img {
-			for j := range img[i] {
-				residualMap[i][j] += img[i][j]
+// gridPeak is identifyMaxPosition for a grid.
+func gridPeak[T Float](g GridOf[T], mask Mask, absolute bool) (Point, float64) {
+	maxPos := Point{}
+	maxIntensity := 0.0
+	maxKey := math.Inf(-1)
+
+	for i := 0; i < g.Width; i++ {
+		for j, v := range g.Row(i) {
+			if !mask.allows(i, j) {
+				continue
+			}
+			val := float64(v)
+			key := val
+			if absolute {
+				key = math.Abs(val)
+			}
+			if exceedsPeak(key, maxKey) {
+				maxKey = key
+				maxIntensity = val
+				maxPos = Point{x: i, y: j}
 			}
 		}
 	}
-	cleanedImage := make(Image, len(cleanComponents))
+
+	return maxPos, maxIntensity
+}
+
+func addResiduals(model Image, residual Image) Image {
+	cleanedImage := newImage(len(model), len(model[0]))
 	for i := range cleanedImage {
-		cleanedImage[i] = make([]float64, len(cleanComponents[i]))
 		for j := range cleanedImage[i] {
-			cleanedImage[i][j] = cleanComponents[i][j] + residualMap[i][j]
+			cleanedImage[i][j] = model[i][j] + residual[i][j]
 		}
 	}
 
 	return cleanedImage
 }
 
-func createDirtyMapsFromACB(data *ACBData, numScales int, imageSize int) PFS {
+// dirtyMaps builds one dirty map per scale on the workers of p. Amplitudes are first summed per
+// scale in index order, and the maps are then filled by disjoint row ranges,
+// so the result does not depend on the number of workers.
+func dirtyMaps(p *workerPool, data *ACBData, numScales int, imageSize int) (PFS, error) {
 	fmt.Println("Creating dirty maps from ACB data...")
-	dirtyMaps := make(PFS, numScales)
+	maps := make(PFS, numScales)
 	for s := 0; s < numScales; s++ {
-		dirtyMaps[s] = make(Image, imageSize)
-		for i := range dirtyMaps[s] {
-			dirtyMaps[s][i] = make([]float64, imageSize)
-		}
+		maps[s] = newImage(imageSize, imageSize)
 	}
 
 	center := imageSize / 2
@@ -370,130 +600,91 @@ func createDirtyMapsFromACB(data *ACBData, numScales int, imageSize int) PFS {
 		scaleSigmas[s] = 1.0 + float64(s)*2.0
 	}
 
-	gaussianLookup := make([][][]float64, numScales)
-	var wg sync.WaitGroup
-	wg.Add(numScales)
+	scaleAmps := make([]float64, numScales)
+	for i, amp := range data.Amplitudes {
+		if i >= len(uniqueFreqs) {
+			break
+		}
+		scaleIndex := int(float64(i) / float64(len(uniqueFreqs)) * float64(numScales))
+		if scaleIndex >= numScales {
+			scaleIndex = numScales - 1
+		}
+		scaleAmps[scaleIndex] += amp
+	}
 
-	for s := 0; s < numScales; s++ {
-		go func(scale int) {
-			defer wg.Done()
-			gaussianLookup[scale] = make([][]float64, imageSize)
-			for i := range gaussianLookup[scale] {
-				gaussianLookup[scale][i] = make([]float64, imageSize)
-				for j := range gaussianLookup[scale][i] {
-					dx := float64(i - center)
-					dy := float64(j - center)
-					distance := math.Sqrt(dx*dx + dy*dy)
-					gaussianLookup[scale][i][j] = math.Exp(-(distance * distance) / (2 * scaleSigmas[scale] * scaleSigmas[scale]))
+	chunks := p.divide(imageSize)
+	err := p.run("dirty maps", len(chunks), func(c int) {
+		for x := chunks[c][0]; x < chunks[c][1]; x++ {
+			dx := float64(x - center)
+			for y := 0; y < imageSize; y++ {
+				dy := float64(y - center)
+				r2 := dx*dx + dy*dy
+				for s := 0; s < numScales; s++ {
+					if scaleAmps[s] != 0 {
+						maps[s][x][y] = scaleAmps[s] * math.Exp(-r2/(2*scaleSigmas[s]*scaleSigmas[s]))
+					}
 				}
 			}
-		}(s)
-	}
-	wg.Wait()
-	pool := newWorkerPool()
-	chunks := pool.divide(len(data.Amplitudes))
-	pool.wg.Add(len(chunks))
-	var mutex sync.Mutex
-	for _, chunk := range chunks {
-		go func(start, end int) {
-			defer pool.wg.Done()
-
-			for i := start; i < end && i < len(data.Amplitudes); i++ {
-				amp := data.Amplitudes[i]
-				if i >= len(uniqueFreqs) {
-					continue
-				}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
 
-				scaleIndex := int(float64(i) / float64(len(uniqueFreqs)) * float64(numScales))
-				if scaleIndex >= numScales {
-					scaleIndex = numScales - 1
-				}
+	return maps, nil
+}
 
-				localUpdates := make([][]float64, imageSize)
-				for x := range localUpdates {
-					localUpdates[x] = make([]float64, imageSize)
-					for y := 0; y < imageSize; y++ {
-						localUpdates[x][y] = amp * gaussianLookup[scaleIndex][x][y]
-					}
-				}
+func createPSFFromACB(imageSize int) Image {
+	fmt.Println("Creating PSF...")
+	psf := newImage(imageSize, imageSize)
 
-				mutex.Lock()
-				for x := 0; x < imageSize; x++ {
-					for y := 0; y < imageSize; y++ {
-						dirtyMaps[scaleIndex][x][y] += localUpdates[x][y]
-					}
-				}
-				mutex.Unlock()
-			}
-		}(chunk[0], chunk[1])
+	center := imageSize / 2
+	sigma := 1.0
+	for x := 0; x < imageSize; x++ {
+		for y := 0; y < imageSize; y++ {
+			dx := float64(x - center)
+			dy := float64(y - center)
+			distance := math.Sqrt(dx*dx + dy*dy)
+			psf[x][y] = math.Exp(-(distance * distance) / (2 * sigma * sigma))
+		}
 	}
-	pool.wg.Wait()
 
-	return dirtyMaps
+	return psf
 }
 
-func createPSFsFromACB(numScales int, imageSize int) PFS {
-	fmt.Println("Creating PSFs...")
-	psfs := make(PFS, numScales)
+// createBasisFunctionsFromACB returns a unit-sum Gaussian of each sigma in
+// scaleSizes; a zero sigma gives a delta function.
+func createBasisFunctionsFromACB(scaleSizes []float64, imageSize int) PFS {
+	numScales := len(scaleSizes)
+	basisFuncs := make(PFS, numScales)
 	for s := 0; s < numScales; s++ {
-		psfs[s] = make(Image, imageSize)
-		for i := range psfs[s] {
-			psfs[s][i] = make([]float64, imageSize)
-		}
+		basisFuncs[s] = newImage(imageSize, imageSize)
 	}
-
 	center := imageSize / 2
-	fmt.Println("Generating PSF patterns...")
-
 	for s := 0; s < numScales; s++ {
-		fmt.Printf("  Scale %d/%d...\n", s+1, numScales)
-		sigma := 1.0 + float64(s)*0.5
-
+		sigma := scaleSizes[s]
+		if sigma == 0 {
+			basisFuncs[s][center][center] = 1
+			continue
+		}
 		for x := 0; x < imageSize; x++ {
 			for y := 0; y < imageSize; y++ {
 				dx := float64(x - center)
 				dy := float64(y - center)
 				distance := math.Sqrt(dx*dx + dy*dy)
-				psfs[s][x][y] = math.Exp(-(distance * distance) / (2 * sigma * sigma))
+				basisFuncs[s][x][y] = math.Exp(-(distance * distance) / (2 * sigma * sigma))
 			}
 		}
 
-		fmt.Println("  Normalizing PSF...")
 		total := 0.0
 		for x := 0; x < imageSize; x++ {
 			for y := 0; y < imageSize; y++ {
-				total += psfs[s][x][y]
-			}
-		}
-		if total > 0 {
-			for x := 0; x < imageSize; x++ {
-				for y := 0; y < imageSize; y++ {
-					psfs[s][x][y] /= total
-				}
+				total += basisFuncs[s][x][y]
 			}
 		}
-	}
-
-	return psfs
-}
-
-func createBasisFunctionsFromACB(numScales int, imageSize int) PFS {
-	basisFuncs := make(PFS, numScales)
-	for s := 0; s < numScales; s++ {
-		basisFuncs[s] = make(Image, imageSize)
-		for i := range basisFuncs[s] {
-			basisFuncs[s][i] = make([]float64, imageSize)
-		}
-	}
-	center := imageSize / 2
-	for s := 0; s < numScales; s++ {
-		sigma := 1.0 + float64(s)*2.0
 		for x := 0; x < imageSize; x++ {
 			for y := 0; y < imageSize; y++ {
-				dx := float64(x - center)
-				dy := float64(y - center)
-				distance := math.Sqrt(dx*dx + dy*dy)
-				basisFuncs[s][x][y] = math.Exp(-(distance * distance) / (2 * sigma * sigma))
+				basisFuncs[s][x][y] /= total
 			}
 		}
 	}
@@ -514,13 +705,25 @@ func getUniqueFrequencies(frequencies []float64) []float64 {
 	return unique
 }
 
-func identifyMaxPosition(img Image) (Point, float64) {
+// identifyMaxPosition returns the position and signed value of the largest
+// pixel in img, or of the largest in absolute value when absolute is set.
+// Only pixels inside mask are considered; the value is zero if there are none.
+func identifyMaxPosition(img Image, mask Mask, absolute bool) (Point, float64) {
 	maxPos := Point{}
-	maxIntensity := math.Inf(-1)
+	maxIntensity := 0.0
+	maxKey := math.Inf(-1)
 
 	for i, row := range img {
 		for j, val := range row {
-			if val > maxIntensity {
+			if !mask.allows(i, j) {
+				continue
+			}
+			key := val
+			if absolute {
+				key = math.Abs(val)
+			}
+			if exceedsPeak(key, maxKey) {
+				maxKey = key
 				maxIntensity = val
 				maxPos = Point{x: i, y: j}
 			}
@@ -530,37 +733,119 @@ func identifyMaxPosition(img Image) (Point, float64) {
 	return maxPos, maxIntensity
 }
 
-func convolve(img1, img2 Image) Image {
-	h1, w1 := len(img1), len(img1[0])
-	h2, w2 := len(img2), len(img2[0])
-	h := h1 + h2 - 1
-	w := w1 + w2 - 1
+// peakTolerance is the relative margin by which a pixel must exceed the
+// running peak to replace it. Pixels that tie exactly on the sky differ only
+// by rounding in the residual, so without it the tie would be broken one way
+// in single precision and another in double precision, or by any change to
+// the FFT sizes.
+const peakTolerance = 1e-6
 
-	result := make(Image, h)
-	for i := range result {
-		result[i] = make([]float64, w)
+// exceedsPeak reports whether key beats the running peak maxKey by more than
+// peakTolerance, so that the first pixel in scan order wins a tie.
+func exceedsPeak(key, maxKey float64) bool {
+	if math.IsInf(maxKey, -1) {
+		return true
 	}
+	return key > maxKey+peakTolerance*math.Abs(maxKey)
+}
 
-	pool := newWorkerPool()
-	chunks := pool.divide(h1)
+// convolve returns the full linear convolution of img1 and img2, of size
+// (h1+h2-1)×(w1+w2-1), computed by FFT.
+func convolve(img1, img2 Image) Image {
+	return convolveGrid(img1, img2, false).Image()
+}
 
-	pool.wg.Add(len(chunks))
-	for _, chunk := range chunks {
-		go func(start, end int) {
-			defer pool.wg.Done()
-			for i := start; i < end; i++ {
+// convolveDirect computes the same result as convolve by direct summation
+// on the workers of p. It is kept as a reference for tests and benchmarks. The output is
+// partitioned by rows so that each worker owns the rows it writes. Every
+// output pixel is summed in the same order whatever the partition, which
+// makes the result bit-identical for any number of workers.
+func convolveDirect(p *workerPool, img1, img2 Image) (Image, error) {
+	h1, w1 := len(img1), len(img1[0])
+	h2, w2 := len(img2), len(img2[0])
+	result := newImage(h1+h2-1, w1+w2-1)
+
+	chunks := p.divide(len(result))
+	err := p.run("convolve", len(chunks), func(c int) {
+		for r := chunks[c][0]; r < chunks[c][1]; r++ {
+			row := result[r]
+			for i := max(0, r-h2+1); i <= min(r, h1-1); i++ {
+				src, krow := img1[i], img2[r-i]
 				for j := 0; j < w1; j++ {
-					for k := 0; k < h2; k++ {
-						for l := 0; l < w2; l++ {
-							result[i+k][j+l] += img1[i][j] * img2[k][l]
-						}
+					v := src[j]
+					for l := 0; l < w2; l++ {
+						row[j+l] += v * krow[l]
 					}
 				}
 			}
-		}(chunk[0], chunk[1])
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// convolveSame returns the convolution of img with kernel cropped to the size
+// of img, with the kernel centred on its middle pixel.
+func convolveSame(img, kernel Image) Image {
+	return convolveGrid(img, kernel, true).Image()
+}
+
+// padCentered embeds img in a zeroed h×w image so that the centre pixel of
+// img lands on the centre pixel of the result.
+func padCentered(img Image, h, w int) Image {
+	result := newImage(h, w)
+	offX := h/2 - len(img)/2
+	offY := w/2 - len(img[0])/2
+	for i := range img {
+		copy(result[i+offX][offY:], img[i])
+	}
+	return result
+}
+
+// addShifted adds amplitude*kernel to img with the kernel centre at pos.
+func addShifted(img, kernel Image, pos Point, amplitude float64) {
+	cx, cy := len(kernel)/2, len(kernel[0])/2
+	for i := range kernel {
+		x := pos.x + i - cx
+		if x < 0 || x >= len(img) {
+			continue
+		}
+		for j := range kernel[i] {
+			y := pos.y + j - cy
+			if y >= 0 && y < len(img[x]) {
+				img[x][y] += amplitude * kernel[i][j]
+			}
+		}
 	}
-	pool.wg.Wait()
+}
+
+// subtractShifted subtracts amplitude*kernel from img with the kernel centre
+// at pos.
+func subtractShifted(img, kernel Image, pos Point, amplitude float64) {
+	addShifted(img, kernel, pos, -amplitude)
+}
+
+// newImage returns a zeroed h×w image backed by a single allocation.
+func newImage(h, w int) Image {
+	return NewGrid(h, w).Image()
+}
 
+func copyImage(img Image) Image {
+	return GridFromImage(img).Image()
+}
+
+func sumMaps(maps PFS) Image {
+	result := newImage(len(maps[0]), len(maps[0][0]))
+	for _, img := range maps {
+		for i := range img {
+			for j := range img[i] {
+				result[i][j] += img[i][j]
+			}
+		}
+	}
 	return result
 }
 
@@ -0,0 +1,119 @@
+package clean
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func identical(a, b Image) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for j := range a[i] {
+			if a[i][j] != b[i][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestConvolveDirectDeterministic(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	img := randomImage(rng, 23, 17)
+	kernel := randomImage(rng, 9, 14)
+	want, _ := convolveDirect(newWorkerPool(Options{Workers: 1}), img, kernel)
+	if d := maxAbsDiff(want, naiveConvolve(img, kernel)); d > 1e-12 {
+		t.Fatalf("direct convolution differs from reference by %g", d)
+	}
+	for workers := 2; workers <= 40; workers += 3 {
+		got, _ := convolveDirect(newWorkerPool(Options{Workers: workers}), img, kernel)
+		if !identical(got, want) {
+			t.Errorf("%d workers: result differs from a single worker", workers)
+		}
+	}
+}
+
+func TestDirtyMapsDeterministic(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	data := &ACBData{}
+	for i := 0; i < 200; i++ {
+		data.Frequencies = append(data.Frequencies, float64(i))
+		data.Amplitudes = append(data.Amplitudes, rng.Float64())
+	}
+	want, _ := dirtyMaps(newWorkerPool(Options{Workers: 1}), data, 5, 33)
+	for workers := 2; workers <= 8; workers++ {
+		got, _ := dirtyMaps(newWorkerPool(Options{Workers: workers}), data, 5, 33)
+		for s := range want {
+			if !identical(got[s], want[s]) {
+				t.Errorf("%d workers: scale %d differs from a single worker", workers, s)
+			}
+		}
+	}
+}
+
+func minorCycleAllocs[T Float](t *testing.T) float64 {
+	dirty, psf := syntheticSky(32)
+	msc, err := NewMultiScaleCleaner(Options{Workers: 4, NumScales: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msc.pool.start()
+	defer msc.pool.stop()
+	msc.imageSize = len(dirty)
+	msc.setPSF(psf)
+	beams, err := multiScaleBeams[T](msc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := newMSWorkspace(msc, beams, GridFromImage(dirty))
+	ws.setMask(nil)
+	model := newImage(32, 32)
+	return testing.AllocsPerRun(100, func() {
+		scale, pos, peak := ws.identifyMaxScale()
+		amplitude := 0.1 * peak / msc.crossPeaks[scale]
+		addShifted(model, msc.basisFuncs[scale], pos, amplitude)
+		ws.updateDirtyMaps(scale, pos, amplitude)
+	})
+}
+
+func TestMinorCycleDoesNotAllocate(t *testing.T) {
+	if n := minorCycleAllocs[float64](t); n != 0 {
+		t.Errorf("float64 minor cycle allocates %v times per iteration", n)
+	}
+	if n := minorCycleAllocs[float32](t); n != 0 {
+		t.Errorf("float32 minor cycle allocates %v times per iteration", n)
+	}
+}
+
+func TestReadACB(t *testing.T) {
+	data, err := ParseACB(testACB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.ObsCode != "E18A24" || data.Source != "BLLAC" || data.Bandwidth != "58.000 MHz" {
+		t.Errorf("header: obscode %q, source %q, bandwidth %q", data.ObsCode, data.Source, data.Bandwidth)
+	}
+	if len(data.Frequencies) != 128 || len(data.Polarizations) != 128 || len(data.Amplitudes) != 7424 {
+		t.Errorf("read %d frequencies, %d polarizations and %d amplitudes, want 128, 128 and 7424",
+			len(data.Frequencies), len(data.Polarizations), len(data.Amplitudes))
+	}
+
+	for _, in := range []string{
+		"",
+		"not an acb file\n",
+		"timerange: 58232\n 1 LM 1\n",
+		"timerange: 58232\n 1 LM 1 Inf\n",
+		"timerange: 58232\nbandfreq: NaN GHz polar: RR\n",
+		"timerange: 58232\nsource: " + strings.Repeat("x", maxACBLineLength) + "\n",
+	} {
+		if _, err := ReadACB(strings.NewReader(in)); err == nil {
+			t.Errorf("ReadACB(%.40q) succeeded", in)
+		}
+	}
+}
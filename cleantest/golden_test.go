@@ -0,0 +1,111 @@
+package cleantest
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mothergoose31/clean"
+	"github.com/mothergoose31/clean/internal/testutil"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden images in testdata")
+
+const blLacACB = "../E18A24.0.bin0000.source0000.acb"
+
+// TestBLLacGolden cleans the bundled BL Lac observation with every
+// deconvolver and compares the restored image with testdata/bllac_<name>.golden.
+// Run with -update after an intended change to the output.
+func TestBLLacGolden(t *testing.T) {
+	for _, name := range clean.Deconvolvers() {
+		t.Run(name, func(t *testing.T) {
+			testutil.Quiet(t)
+			result, err := clean.CleanACB(blLacACB, name, 64, nil, clean.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", "bllac_"+name+".golden")
+			if *update {
+				if err := writeGolden(golden, result.Restored); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := readGolden(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if len(want) != len(result.Restored) || len(want[0]) != len(result.Restored[0]) {
+				t.Fatalf("restored image is %dx%d, golden %dx%d",
+					len(result.Restored), len(result.Restored[0]), len(want), len(want[0]))
+			}
+			tol := 1e-6 * Peak(want)
+			for x := range want {
+				for y := range want[x] {
+					if d := math.Abs(result.Restored[x][y] - want[x][y]); d > tol {
+						t.Fatalf("pixel (%d, %d) = %.9g, golden %.9g", x, y, result.Restored[x][y], want[x][y])
+					}
+				}
+			}
+		})
+	}
+}
+
+// writeGolden stores img as text: a "width height" line followed by one line
+// of values per x.
+func writeGolden(filename string, img clean.Image) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "%d %d\n", len(img), len(img[0]))
+	for _, row := range img {
+		for y, v := range row {
+			if y > 0 {
+				w.WriteByte(' ')
+			}
+			w.WriteString(strconv.FormatFloat(v, 'g', 12, 64))
+		}
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readGolden(filename string) (clean.Image, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var width, height int
+	if _, err := fmt.Sscan(lines[0], &width, &height); err != nil {
+		return nil, fmt.Errorf("%s: bad header: %v", filename, err)
+	}
+	if len(lines) != width+1 {
+		return nil, fmt.Errorf("%s: %d rows, want %d", filename, len(lines)-1, width)
+	}
+	img := clean.NewGrid(width, height).Image()
+	for x, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) != height {
+			return nil, fmt.Errorf("%s: row %d has %d values, want %d", filename, x, len(fields), height)
+		}
+		for y, field := range fields {
+			if img[x][y], err = strconv.ParseFloat(field, 64); err != nil {
+				return nil, fmt.Errorf("%s: row %d: %v", filename, x, err)
+			}
+		}
+	}
+	return img, nil
+}
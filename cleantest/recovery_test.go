@@ -0,0 +1,95 @@
+package cleantest
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mothergoose31/clean"
+	"github.com/mothergoose31/clean/internal/testutil"
+)
+
+const size = 64
+
+type sourceCheck struct {
+	src    Source
+	radius float64 // components within this many pixels count towards src
+}
+
+var skies = []struct {
+	name    string
+	sources []sourceCheck
+	// fluxTol is the allowed fractional flux error per source.
+	fluxTol float64
+}{
+	{
+		name:    "point",
+		sources: []sourceCheck{{Source{X: 35, Y: 27, Flux: 2}, 3}},
+		fluxTol: 0.02,
+	},
+	{
+		name:    "gaussian",
+		sources: []sourceCheck{{Source{X: 30.4, Y: 33.6, Flux: 5, Sigma: 3}, 12}},
+		fluxTol: 0.1,
+	},
+	{
+		name: "multi",
+		sources: []sourceCheck{
+			{Source{X: 20, Y: 20, Flux: 3}, 4},
+			{Source{X: 44, Y: 24, Flux: 1}, 4},
+			{Source{X: 32.3, Y: 44.7, Flux: 4, Sigma: 2.5}, 10},
+		},
+		fluxTol: 0.1,
+	},
+}
+
+var psfs = []struct {
+	name string
+	psf  clean.Image
+}{
+	{"gaussian", GaussianPSF(size, 1.5)},
+	{"ring", RingPSF(size, 1.5, 8, 0.03)},
+}
+
+func TestRecovery(t *testing.T) {
+	for _, sk := range skies {
+		var sources []Source
+		for _, sc := range sk.sources {
+			sources = append(sources, sc.src)
+		}
+		sky := Sky(size, sources...)
+		for _, p := range psfs {
+			dirty := Dirty(sky, p.psf)
+			for _, name := range clean.Deconvolvers() {
+				t.Run(sk.name+"/"+p.name+"/"+name, func(t *testing.T) {
+					d, err := clean.NewDeconvolver(name)
+					if err != nil {
+						t.Fatal(err)
+					}
+					testutil.Quiet(t)
+					result, err := d.Deconvolve(dirty, p.psf, nil, clean.Options{
+						MaxIterations: 3000,
+						Threshold:     1e-3 * Peak(dirty),
+						ScaleSizes:    []float64{0, 2, 4},
+					})
+					if err != nil {
+						t.Fatal(err)
+					}
+					for _, sc := range sk.sources {
+						s := sc.src
+						flux := FluxWithin(result.Components, s.X, s.Y, sc.radius)
+						if math.Abs(flux-s.Flux) > sk.fluxTol*s.Flux {
+							t.Errorf("source at (%g, %g): flux %.4g, want %g", s.X, s.Y, flux, s.Flux)
+						}
+						x, y := Centroid(result.Components, s.X, s.Y, sc.radius)
+						if d := math.Hypot(x-s.X, y-s.Y); !(d <= 0.5) {
+							t.Errorf("source at (%g, %g): centroid (%.2f, %.2f) is %.2f px away", s.X, s.Y, x, y, d)
+						}
+					}
+					if rms := RMS(result.Residual); rms > 0.01*Peak(dirty) {
+						t.Errorf("residual RMS %.3g is more than 1%% of the dirty peak %.3g", rms, Peak(dirty))
+					}
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Package cleantest builds synthetic skies with known sources and PSFs for
+// checking that the deconvolvers recover them.
+package cleantest
+
+import (
+	"math"
+
+	"github.com/mothergoose31/clean"
+)
+
+// Source is one component of a synthetic sky: a point at the pixel nearest
+// (X, Y) when Sigma is zero, otherwise a circular Gaussian with standard
+// deviation Sigma pixels. Flux is the total flux of the source.
+type Source struct {
+	X, Y  float64
+	Flux  float64
+	Sigma float64
+}
+
+// Sky renders sources on an n×n image.
+func Sky(n int, sources ...Source) clean.Image {
+	sky := clean.NewGrid(n, n).Image()
+	for _, s := range sources {
+		if s.Sigma == 0 {
+			sky[int(math.Round(s.X))][int(math.Round(s.Y))] += s.Flux
+			continue
+		}
+		norm := s.Flux / (2 * math.Pi * s.Sigma * s.Sigma)
+		for x := range sky {
+			for y := range sky[x] {
+				dx, dy := float64(x)-s.X, float64(y)-s.Y
+				sky[x][y] += norm * math.Exp(-(dx*dx+dy*dy)/(2*s.Sigma*s.Sigma))
+			}
+		}
+	}
+	return sky
+}
+
+// GaussianPSF returns an n×n circular Gaussian PSF of standard deviation
+// sigma pixels, with a peak of 1 at the centre pixel.
+func GaussianPSF(n int, sigma float64) clean.Image {
+	return RingPSF(n, sigma, 0, 0)
+}
+
+// RingPSF returns GaussianPSF with a negative sidelobe ring of the given
+// depth at radius pixels, as left by an interferometer's missing short
+// spacings.
+func RingPSF(n int, sigma, radius, depth float64) clean.Image {
+	psf := clean.NewGrid(n, n).Image()
+	c := float64(n / 2)
+	for x := range psf {
+		for y := range psf[x] {
+			r := math.Hypot(float64(x)-c, float64(y)-c)
+			psf[x][y] = math.Exp(-r * r / (2 * sigma * sigma))
+			if depth != 0 {
+				psf[x][y] -= depth * math.Exp(-(r-radius)*(r-radius)/(2*sigma*sigma))
+			}
+		}
+	}
+	return psf
+}
+
+// Dirty convolves sky with psf, centred on the PSF's middle pixel, and
+// returns an image the size of sky.
+func Dirty(sky, psf clean.Image) clean.Image {
+	n, m := len(sky), len(sky[0])
+	cx, cy := len(psf)/2, len(psf[0])/2
+	dirty := clean.NewGrid(n, m).Image()
+	for i := range sky {
+		for j, v := range sky[i] {
+			if v == 0 {
+				continue
+			}
+			for k := range psf {
+				x := i + k - cx
+				if x < 0 || x >= n {
+					continue
+				}
+				for l, p := range psf[k] {
+					if y := j + l - cy; y >= 0 && y < m {
+						dirty[x][y] += v * p
+					}
+				}
+			}
+		}
+	}
+	return dirty
+}
+
+// FluxWithin returns the total flux of the components within r pixels of
+// (x, y).
+func FluxWithin(comps []clean.Component, x, y, r float64) float64 {
+	total := 0.0
+	for _, c := range comps {
+		if math.Hypot(float64(c.X)-x, float64(c.Y)-y) <= r {
+			total += c.Flux
+		}
+	}
+	return total
+}
+
+// Centroid returns the flux-weighted position of the components within r
+// pixels of (x, y).
+func Centroid(comps []clean.Component, x, y, r float64) (float64, float64) {
+	var sum, sx, sy float64
+	for _, c := range comps {
+		if math.Hypot(float64(c.X)-x, float64(c.Y)-y) <= r {
+			sum += c.Flux
+			sx += c.Flux * float64(c.X)
+			sy += c.Flux * float64(c.Y)
+		}
+	}
+	if sum == 0 {
+		return math.NaN(), math.NaN()
+	}
+	return sx / sum, sy / sum
+}
+
+// RMS returns the root mean square of img.
+func RMS(img clean.Image) float64 {
+	sum, n := 0.0, 0
+	for _, row := range img {
+		for _, v := range row {
+			sum += v * v
+			n++
+		}
+	}
+	return math.Sqrt(sum / float64(n))
+}
+
+// Peak returns the largest absolute value in img.
+func Peak(img clean.Image) float64 {
+	peak := 0.0
+	for _, row := range img {
+		for _, v := range row {
+			peak = math.Max(peak, math.Abs(v))
+		}
+	}
+	return peak
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/mothergoose31/clean"
+)
+
+const (
+	axisMargin = 48
+	tickLength = 6
+	maxTicks   = 7
+	glyphScale = 2
+)
+
+// glyphs is a 3×5 bitmap font for tick labels, one string of three
+// pixels per row.
+var glyphs = map[rune][5]string{
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"###", "..#", "###", "#..", "###"},
+	'3': {"###", "..#", "###", "..#", "###"},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "###", "..#", "###"},
+	'6': {"###", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", "..#", "..#", "..#"},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "###"},
+	'-': {"...", "...", "###", "...", "..."},
+	'.': {"...", "...", "...", "...", ".#."},
+}
+
+// drawAxes returns src inside a margin carrying tick marks and labels for
+// the offsets from the phase centre of wcs, in the units of
+// wcs.AxisLabel. Row y of src is pixel h-1-y of the image, as colorize
+// puts north up.
+func drawAxes(src *image.RGBA, wcs clean.WCS) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w+2*axisMargin, h+2*axisMargin))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	origin := image.Pt(axisMargin, axisMargin)
+	draw.Draw(dst, src.Bounds().Add(origin), src, image.Point{}, draw.Src)
+
+	black := color.RGBA{A: 255}
+	frame := image.Rect(axisMargin-1, axisMargin-1, axisMargin+w+1, axisMargin+h+1)
+	for x := frame.Min.X; x < frame.Max.X; x++ {
+		dst.Set(x, frame.Min.Y, black)
+		dst.Set(x, frame.Max.Y-1, black)
+	}
+	for y := frame.Min.Y; y < frame.Max.Y; y++ {
+		dst.Set(frame.Min.X, y, black)
+		dst.Set(frame.Max.X-1, y, black)
+	}
+
+	for _, t := range wcs.Ticks(0, w, maxTicks) {
+		x := axisMargin + int(t.Pixel+0.5)
+		for d := 1; d <= tickLength; d++ {
+			dst.Set(x, frame.Max.Y-1+d, black)
+		}
+		width := labelWidth(t.Label)
+		drawLabel(dst, t.Label, x-width/2, frame.Max.Y+tickLength+4, black)
+	}
+	for _, t := range wcs.Ticks(1, h, maxTicks) {
+		y := axisMargin + h - 1 - int(t.Pixel+0.5)
+		for d := 1; d <= tickLength; d++ {
+			dst.Set(frame.Min.X-d, y, black)
+		}
+		width := labelWidth(t.Label)
+		drawLabel(dst, t.Label, frame.Min.X-tickLength-4-width, y-5*glyphScale/2, black)
+	}
+	return dst
+}
+
+func labelWidth(s string) int {
+	n := len([]rune(s))
+	return n*4*glyphScale - glyphScale
+}
+
+// drawLabel draws s with its top-left corner at (x, y).
+func drawLabel(dst *image.RGBA, s string, x, y int, c color.Color) {
+	for _, r := range s {
+		g, ok := glyphs[r]
+		if ok {
+			for row, bits := range g {
+				for col := range bits {
+					if bits[col] != '#' {
+						continue
+					}
+					for dy := 0; dy < glyphScale; dy++ {
+						for dx := 0; dx < glyphScale; dx++ {
+							dst.Set(x+col*glyphScale+dx, y+row*glyphScale+dy, c)
+						}
+					}
+				}
+			}
+		}
+		x += 4 * glyphScale
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/mothergoose31/clean"
+)
+
+// TestDrawAxesTicks checks that every tick of both axes is drawn against
+// its pixel, with Dec ticks counted up from the bottom row.
+func TestDrawAxesTicks(t *testing.T) {
+	for _, tc := range []struct {
+		w, h       int
+		cell       clean.Angle
+		refX, refY float64
+	}{
+		{64, 64, clean.Milliarcsecond, 32, 32},
+		{64, 40, 0.1 * clean.Milliarcsecond, 10, 30},
+		{2048, 2048, 50 * clean.Microarcsecond, 1024, 1024},
+	} {
+		wcs := clean.NewWCS(tc.w, tc.h, tc.cell)
+		wcs.RefX, wcs.RefY = tc.refX, tc.refY
+		dst := drawAxes(image.NewRGBA(image.Rect(0, 0, tc.w, tc.h)), wcs)
+		if b := dst.Bounds(); b.Dx() != tc.w+2*axisMargin || b.Dy() != tc.h+2*axisMargin {
+			t.Errorf("%+v: framed image is %v", tc, b)
+		}
+		black := func(x, y int) bool { return dst.RGBAAt(x, y) == color.RGBA{A: 255} }
+
+		xTicks, yTicks := wcs.Ticks(0, tc.w, maxTicks), wcs.Ticks(1, tc.h, maxTicks)
+		if len(xTicks) < 2 || len(yTicks) < 2 {
+			t.Errorf("%+v: %d RA and %d Dec ticks, want at least 2 each", tc, len(xTicks), len(yTicks))
+		}
+		for _, tick := range xTicks {
+			x := axisMargin + int(tick.Pixel+0.5)
+			if !black(x, axisMargin+tc.h+tickLength-1) {
+				t.Errorf("%+v: no RA tick %s below column %d", tc, tick.Label, x)
+			}
+		}
+		for _, tick := range yTicks {
+			y := axisMargin + tc.h - 1 - int(tick.Pixel+0.5)
+			if !black(axisMargin-tickLength, y) {
+				t.Errorf("%+v: no Dec tick %s beside row %d", tc, tick.Label, y)
+			}
+		}
+	}
+}
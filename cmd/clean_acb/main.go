@@ -1,19 +1,74 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"image"
 	"image/png"
+	"io"
 	"log"
 	"math"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/mothergoose31/clean"
 )
 
-func saveImageAsPNG(img clean.Image, filename string) error {
+// stageTimes accumulates the task timings of each parallel stage.
+type stageTimes map[string]*stageTime
+
+type stageTime struct {
+	tasks      int
+	total, max time.Duration
+}
+
+func (st stageTimes) add(t clean.TaskTiming) {
+	s := st[t.Stage]
+	if s == nil {
+		s = &stageTime{}
+		st[t.Stage] = s
+	}
+	s.tasks++
+	s.total += t.Duration
+	s.max = max(s.max, t.Duration)
+}
+
+func (st stageTimes) print() {
+	names := make([]string, 0, len(st))
+	for name := range st {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return st[names[i]].total > st[names[j]].total })
+	fmt.Println("Parallel stage timings:")
+	for _, name := range names {
+		s := st[name]
+		fmt.Printf("  %-18s %6d tasks  total %-12v mean %-12v max %v\n",
+			name, s.tasks, s.total, s.total/time.Duration(s.tasks), s.max)
+	}
+}
+
+// colormapLUTSize is the number of colours colorize samples from the
+// colormap, enough to stay within one level of interpolating each pixel.
+const colormapLUTSize = 4096
+
+func saveImageAsPNG(img clean.Image, filename string, cmap clean.Continuous, symmetric bool) error {
+	return writePNG(colorize(img, cmap, symmetric), filename)
+}
+
+// colorize maps img onto cmap, scaled to its full range, or to ±its largest
+// magnitude if symmetric so that zero falls at the middle of a diverging map.
+// Pixel y = 0 is the bottom row, so north is up and east to the left.
+func colorize(img clean.Image, cmap clean.Continuous, symmetric bool) *image.RGBA {
 	minVal := math.Inf(1)
 	maxVal := math.Inf(-1)
 
@@ -27,6 +82,10 @@ func saveImageAsPNG(img clean.Image, filename string) error {
 			}
 		}
 	}
+	if symmetric {
+		maxVal = math.Max(math.Abs(minVal), math.Abs(maxVal))
+		minVal = -maxVal
+	}
 	imgWidth := len(img)
 	imgHeight := len(img[0])
 	pngImg := image.NewRGBA(image.Rect(0, 0, imgWidth, imgHeight))
@@ -36,21 +95,27 @@ func saveImageAsPNG(img clean.Image, filename string) error {
 		scale = 1.0 / (maxVal - minVal)
 	}
 
+	lut := clean.NewLUT(cmap, colormapLUTSize)
 	for y := 0; y < imgHeight; y++ {
 		for x := 0; x < imgWidth; x++ {
 			if x < len(img) && y < len(img[x]) {
 				normalizedValue := (img[x][y] - minVal) * scale
-				pngImg.Set(x, y, clean.Viridis.ColorAt(normalizedValue))
+				// PNG rows run down the page; north, +y, is up.
+				pngImg.SetRGBA(x, imgHeight-1-y, lut.At(normalizedValue))
 			}
 		}
 	}
+	return pngImg
+}
+
+func writePNG(img image.Image, filename string) error {
 	f, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	return png.Encode(f, pngImg)
+	return png.Encode(f, img)
 }
 
 func upsampleImage(img clean.Image, targetWidth, targetHeight int) clean.Image {
@@ -98,37 +163,583 @@ func upsampleImage(img clean.Image, targetWidth, targetHeight int) clean.Image {
 	return result
 }
 
+// errUsage reports a command line the flag package could not parse; it has
+// already printed the error and the usage.
+var errUsage = errors.New("invalid command line")
+
+// config holds the command-line flags.
+type config struct {
+	input         string
+	dirty         string
+	psf           string
+	plane         int
+	output        string
+	algorithm     string
+	numScales     int
+	scaleSizes    string
+	scaleBias     string
+	gain          float64
+	threshold     float64
+	maxIterations int
+	noiseSigma    float64
+	fluxTolerance float64
+	stopDiverging bool
+	timeLimit     time.Duration
+	maskBoxes     string
+	maskCircles   string
+	maskRegions   string
+	autoMask      float64
+	cellSize      string
+	ra            string
+	dec           string
+	projection    string
+	axes          bool
+	cmap          string
+	residualCmap  string
+	modFile       string
+	ccFile        string
+	jsonFile      string
+	startModel    string
+	imageSize     int
+	highRes       bool
+	absPeak       bool
+	positiveOnly  bool
+	bmaj          float64
+	bmin          float64
+	bpa           float64
+	modelFile     string
+	residualFile  string
+	writeFITS     bool
+	bitpix        int
+	telescope     string
+	supportCutoff float64
+	float32       bool
+	workers       int
+	cpuProfile    string
+	memProfile    string
+	timing        bool
+}
+
+// fitsInput reports whether the dirty image and PSF come from FITS files
+// rather than an ACB file.
+func (c *config) fitsInput() bool {
+	return c.dirty != "" || c.psf != ""
+}
+
+// parseFlags parses the command-line arguments args, without the program
+// name, and checks the values that do not depend on the input.
+func parseFlags(args []string) (*config, error) {
+	var c config
+	fs := flag.NewFlagSet("clean_acb", flag.ContinueOnError)
+	fs.StringVar(&c.input, "input", "", "Input ACB file")
+	fs.StringVar(&c.dirty, "dirty", "", "Dirty image FITS file to clean instead of -input (requires -psf)")
+	fs.StringVar(&c.psf, "psf", "", "PSF FITS file for -dirty, the same size with its peak at the centre pixel")
+	fs.IntVar(&c.plane, "plane", 0, "Plane of -dirty and -psf to read from a FITS cube")
+	fs.StringVar(&c.output, "output", "cleaned_image.png", "Output image file")
+	fs.StringVar(&c.algorithm, "algorithm", "multiscale", "CLEAN algorithm: "+strings.Join(clean.Deconvolvers(), ", "))
+	fs.IntVar(&c.numScales, "scales", 5, "Number of scales for Multi-scale CLEAN")
+	fs.StringVar(&c.scaleSizes, "scale-sizes", "", "Comma-separated scale sigmas in pixels, 0 for point components (overrides -scales)")
+	fs.StringVar(&c.scaleBias, "scale-bias", "sqrt", "Scale bias: sqrt for 1/sqrt(s+1), or a Cornwell bias factor in [0, 1)")
+	fs.Float64Var(&c.gain, "gain", 0.1, "Loop gain, in (0, 1]")
+	fs.Float64Var(&c.threshold, "threshold", 1e-5, "Stop when the peak residual falls below this value")
+	fs.IntVar(&c.maxIterations, "niter", 50, "Maximum number of CLEAN iterations")
+	fs.Float64Var(&c.noiseSigma, "nsigma", 0, "Stop when the peak falls below this many times the residual noise (0 disables)")
+	fs.Float64Var(&c.fluxTolerance, "flux-tol", 0, "Stop when the model flux changes by less than this fraction over 10 components (0 disables)")
+	fs.BoolVar(&c.stopDiverging, "stop-diverging", false, "Stop when the residual RMS keeps rising")
+	fs.DurationVar(&c.timeLimit, "time-limit", 0, "Wall-clock budget for the CLEAN iterations, e.g. 30s (0 disables)")
+	fs.StringVar(&c.maskBoxes, "mask-box", "", "Clean boxes as x0,y0,x1,y1 pixel bounds separated by ';'")
+	fs.StringVar(&c.maskCircles, "mask-circle", "", "Clean circles as cx,cy,r in pixels separated by ';'")
+	fs.StringVar(&c.maskRegions, "mask-region", "", "DS9 region file (image coordinates) defining the clean mask")
+	fs.Float64Var(&c.autoMask, "automask", 0, "Grow the clean mask from residual peaks above this many sigma (0 disables)")
+	fs.StringVar(&c.cellSize, "cellsize", "1", "Pixel size with an optional unit (uas, mas, arcsec; mas if none), taken from -dirty when it has one")
+	fs.StringVar(&c.ra, "ra", "0", "Phase centre right ascension as hh:mm:ss.s or degrees")
+	fs.StringVar(&c.dec, "dec", "0", "Phase centre declination as dd:mm:ss.s or degrees")
+	fs.StringVar(&c.projection, "projection", "SIN", "Sky projection: SIN or TAN")
+	fs.BoolVar(&c.axes, "axes", false, "Frame PNG images with ticks labelled in offsets from the phase centre")
+	fs.StringVar(&c.cmap, "cmap", "viridis", "PNG colormap: "+strings.Join(clean.Colormaps(), ", ")+", with _r to reverse, or a CSV file of r,g,b stops")
+	fs.StringVar(&c.residualCmap, "residual-cmap", "", "Colormap for the -residual PNG, scaled symmetrically about zero (defaults to -cmap over the full range)")
+	fs.StringVar(&c.modFile, "components-mod", "", "Write the CLEAN components to this Difmap model file")
+	fs.StringVar(&c.ccFile, "components-fits", "", "Write the CLEAN components to this FITS file as an AIPS CC table")
+	fs.StringVar(&c.jsonFile, "components-json", "", "Write the CLEAN components to this JSON file")
+	fs.StringVar(&c.startModel, "start-model", "", "Difmap model file to subtract before cleaning")
+	fs.IntVar(&c.imageSize, "size", 256, "Size of the output image")
+	fs.BoolVar(&c.highRes, "2k", false, "Generate 2K resolution image (2048x2048)")
+	fs.BoolVar(&c.absPeak, "abspeak", false, "Search peaks on absolute value and allow negative components")
+	fs.BoolVar(&c.positiveOnly, "positive", false, "Stop cleaning at the first negative component (with -abspeak)")
+	fs.Float64Var(&c.bmaj, "bmaj", 0, "Restoring beam major axis FWHM in pixels (0 fits the PSF)")
+	fs.Float64Var(&c.bmin, "bmin", 0, "Restoring beam minor axis FWHM in pixels (defaults to -bmaj)")
+	fs.Float64Var(&c.bpa, "bpa", 0, "Restoring beam position angle in degrees")
+	fs.StringVar(&c.modelFile, "model", "", "Also save the CLEAN model image to this file")
+	fs.StringVar(&c.residualFile, "residual", "", "Also save the residual image to this file")
+	fs.BoolVar(&c.writeFITS, "fits", false, "Also save each image as FITS, named after the PNG with a .fits extension")
+	fs.IntVar(&c.bitpix, "bitpix", -32, "FITS pixel format: -32 for single or -64 for double precision")
+	fs.StringVar(&c.telescope, "telescope", "", "Telescope recorded as TELESCOP in FITS headers when the input does not name one (omitted if empty)")
+	fs.Float64Var(&c.supportCutoff, "support-cutoff", 1e-6, "Truncate PSF and scale kernels below this fraction of their peak (0 keeps them whole)")
+	fs.BoolVar(&c.float32, "float32", false, "Keep multi-scale beams and residual maps in single precision to lower peak memory")
+	fs.IntVar(&c.workers, "workers", 0, "Goroutines per parallel stage (0 uses GOMAXPROCS)")
+	fs.StringVar(&c.cpuProfile, "cpuprofile", "", "Write a CPU profile of the run to this file")
+	fs.StringVar(&c.memProfile, "memprofile", "", "Write a heap profile at the end of the run to this file")
+	fs.BoolVar(&c.timing, "timing", false, "Print the time spent in each parallel stage")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil, err
+		}
+		return nil, errUsage
+	}
+
+	if c.fitsInput() == (c.input != "") {
+		return nil, fmt.Errorf("specify an ACB file with -input, or FITS images with -dirty and -psf")
+	}
+	if c.fitsInput() && (c.dirty == "" || c.psf == "") {
+		return nil, fmt.Errorf("-dirty and -psf must be given together")
+	}
+	if c.numScales < 1 {
+		return nil, fmt.Errorf("-scales %d must be at least 1", c.numScales)
+	}
+	if c.gain <= 0 {
+		return nil, fmt.Errorf("-gain %g must be in (0, 1]", c.gain)
+	}
+	if c.maxIterations < 1 {
+		return nil, fmt.Errorf("-niter %d must be at least 1", c.maxIterations)
+	}
+	if c.bitpix != -32 && c.bitpix != -64 {
+		return nil, fmt.Errorf("-bitpix %d must be -32 or -64", c.bitpix)
+	}
+	return &c, nil
+}
+
+// options returns the clean options the flags select for an image placed
+// by wcs. The restoring beam defaults to beam, read from the input, when
+// -bmaj is not given.
+func (c *config) options(wcs clean.WCS, beam clean.Beam) (clean.Options, error) {
+	if c.bmaj > 0 {
+		beam = clean.Beam{BMaj: c.bmaj, BMin: c.bmin, BPA: c.bpa}
+		if beam.BMin <= 0 {
+			beam.BMin = beam.BMaj
+		}
+	}
+	opts := clean.Options{
+		Gain:          c.gain,
+		Threshold:     c.threshold,
+		MaxIterations: c.maxIterations,
+		NumScales:     c.numScales,
+		AbsolutePeak:  c.absPeak,
+		PositiveOnly:  c.positiveOnly,
+		Beam:          beam,
+
+		NoiseThreshold:   c.noiseSigma,
+		FluxTolerance:    c.fluxTolerance,
+		StopOnDivergence: c.stopDiverging,
+		TimeLimit:        c.timeLimit,
+		AutoMaskSigma:    c.autoMask,
+		Workers:          c.workers,
+		Float32:          c.float32,
+		WCS:              wcs,
+		SupportCutoff:    c.supportCutoff,
+		// The flags always give a threshold and a support cutoff, so
+		// -threshold 0 and -support-cutoff 0 mean zero.
+		Set: clean.SetThreshold | clean.SetSupportCutoff,
+	}
+	if c.scaleSizes != "" {
+		sizes, err := parseFloatList(c.scaleSizes)
+		if err != nil {
+			return opts, fmt.Errorf("invalid -scale-sizes: %v", err)
+		}
+		opts.NumScales = 0
+		opts.ScaleSizes = sizes
+	}
+	if c.scaleBias != "sqrt" {
+		b, err := strconv.ParseFloat(c.scaleBias, 64)
+		if err != nil || b < 0 || b >= 1 {
+			return opts, fmt.Errorf("-scale-bias must be sqrt or a number in [0, 1), got %q", c.scaleBias)
+		}
+		opts.ScaleBias = clean.CornwellScaleBias(b)
+	}
+	if err := opts.Validate(); err != nil {
+		return opts, fmt.Errorf("invalid options: %v", err)
+	}
+	return opts, nil
+}
+
 func main() {
-	inputFile := flag.String("input", "", "Input ACB file")
-	outputFile := flag.String("output", "cleaned_image.png", "Output image file")
-	numScales := flag.Int("scales", 5, "Number of scales for Multi-scale CLEAN")
-	imageSize := flag.Int("size", 256, "Size of the output image")
-	highRes := flag.Bool("2k", false, "Generate 2K resolution image (2048x2048)")
-	flag.Parse()
-	if *inputFile == "" {
-		fmt.Println("Please specify an input file with -input")
-		os.Exit(1)
-	}
-
-	outputDir := filepath.Dir(*outputFile)
-	if outputDir != "." && outputDir != "" {
-		if err := os.MkdirAll(outputDir, 0755); err != nil {
-			log.Fatalf("Failed to create output directory: %v", err)
+	switch err := run(os.Args[1:]); {
+	case err == nil, err == flag.ErrHelp:
+	case err == errUsage:
+		os.Exit(2)
+	default:
+		log.Fatal(err)
+	}
+}
+
+// run cleans the input named by the command-line arguments args and saves
+// the images and components they ask for.
+func run(args []string) error {
+	c, err := parseFlags(args)
+	if err != nil {
+		return err
+	}
+
+	// In FITS mode the images set the size, cell size and metadata.
+	var dirty, psf clean.Image
+	var meta clean.FITSMetadata
+	if c.fitsInput() {
+		dirty, meta, err = readFITSFile(c.dirty, c.plane)
+		if err != nil {
+			return fmt.Errorf("failed to read dirty image: %v", err)
+		}
+		psf, _, err = readFITSFile(c.psf, c.plane)
+		if err != nil {
+			return fmt.Errorf("failed to read PSF: %v", err)
+		}
+		c.imageSize = len(dirty)
+	}
+	if c.imageSize < 2 {
+		return fmt.Errorf("-size %d must be at least 2", c.imageSize)
+	}
+	wcs := meta.WCS
+	if wcs.Cell == 0 {
+		if wcs, err = parseWCS(c.imageSize, c.cellSize, c.ra, c.dec, c.projection); err != nil {
+			return fmt.Errorf("invalid sky coordinates: %v", err)
 		}
 	}
-	fmt.Printf("Applying Multi-scale CLEAN to %s with %d scales...\n", *inputFile, *numScales)
-	cleanedImage, err := clean.CleanACB(*inputFile, *numScales, *imageSize)
+
+	cmap, err := loadColormap(c.cmap)
 	if err != nil {
-		log.Fatalf("Failed to clean ACB data: %v", err)
+		return fmt.Errorf("invalid -cmap: %v", err)
 	}
-	if *highRes {
-		fmt.Println("Upsampling to 2K resolution...")
-		cleanedImage = upsampleImage(cleanedImage, 2048, 2048)
+	residualCmap, symmetricResidual := cmap, false
+	if c.residualCmap != "" {
+		if residualCmap, err = loadColormap(c.residualCmap); err != nil {
+			return fmt.Errorf("invalid -residual-cmap: %v", err)
+		}
+		symmetricResidual = true
+	}
+	opts, err := c.options(wcs, meta.Beam)
+	if err != nil {
+		return err
+	}
+	stopProfiles, err := startProfiles(c.cpuProfile, c.memProfile)
+	if err != nil {
+		return fmt.Errorf("failed to start profiling: %v", err)
+	}
+	defer stopProfiles()
+
+	// Ctrl-C stops cleaning early; the partial result is still saved.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	opts.Context = ctx
+	stages := stageTimes{}
+	if c.timing {
+		opts.TaskTimer = stages.add
+	}
+	if c.startModel != "" {
+		f, err := os.Open(c.startModel)
+		if err != nil {
+			return fmt.Errorf("failed to open start model: %v", err)
+		}
+		opts.StartModel, err = clean.ReadDifmapModel(f, c.imageSize, wcs)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read start model: %v", err)
+		}
+	}
+	mask, err := buildMask(c.imageSize, c.maskBoxes, c.maskCircles, c.maskRegions)
+	if err != nil {
+		return fmt.Errorf("invalid mask: %v", err)
+	}
+
+	source := c.input
+	if c.fitsInput() {
+		source = c.dirty
+	}
+	if c.algorithm == "multiscale" {
+		fmt.Printf("Applying Multi-scale CLEAN to %s with %d scales...\n", source, max(len(opts.ScaleSizes), opts.NumScales))
+	} else {
+		fmt.Printf("Applying %s CLEAN to %s...\n", c.algorithm, source)
+	}
+	var result *clean.CleanResult
+	if c.fitsInput() {
+		result, err = clean.CleanImage(dirty, psf, c.algorithm, mask, opts)
+		if err != nil {
+			return fmt.Errorf("failed to clean %s: %v", c.dirty, err)
+		}
+	} else {
+		data, err := clean.ParseACB(c.input)
+		if err != nil {
+			return fmt.Errorf("failed to read ACB data: %v", err)
+		}
+		meta, err = data.FITSMetadata()
+		if err != nil {
+			return fmt.Errorf("failed to read ACB header: %v", err)
+		}
+		result, err = clean.CleanACBData(data, c.algorithm, c.imageSize, mask, opts)
+		if err != nil {
+			return fmt.Errorf("failed to clean ACB data: %v", err)
+		}
 	}
-	fmt.Printf("Saving cleaned image to %s...\n", *outputFile)
-	if err := saveImageAsPNG(cleanedImage, *outputFile); err != nil {
-		log.Fatalf("Failed to save image: %v", err)
+	fmt.Printf("Stopped after %d iterations: %v\n", result.Stats.Iterations, result.StopReason)
+	fmt.Printf("Model flux: %g, peak residual: %g, residual RMS: %g\n",
+		result.Stats.ModelFlux, result.Stats.PeakResidual, result.Stats.ResidualRMS)
+	fmt.Printf("Restoring beam: %v\n", result.Beam)
+	fmt.Printf("Image: %d pixels of %v, %v projection about RA %.6f deg, Dec %.6f deg\n",
+		c.imageSize, result.WCS.Cell, result.WCS.Projection, result.WCS.RA.Degrees(), result.WCS.Dec.Degrees())
+	if c.timing {
+		stages.print()
+	}
+
+	if meta.Telescope == "" {
+		meta.Telescope = c.telescope
+	}
+	meta.WCS = result.WCS
+	meta.Beam = result.Beam
+	var axesWCS *clean.WCS
+	if c.axes {
+		axesWCS = &result.WCS
+		fmt.Printf("Axes: %s across, %s up\n", result.WCS.AxisLabel(0, c.imageSize), result.WCS.AxisLabel(1, c.imageSize))
+	}
+	products := []struct {
+		img       clean.Image
+		filename  string
+		bunit     string
+		cmap      clean.Continuous
+		symmetric bool
+	}{
+		{result.Restored, c.output, "JY/BEAM", cmap, false},
+		{result.Model, c.modelFile, "JY/PIXEL", cmap, false},
+		{result.Residual, c.residualFile, "JY/BEAM", residualCmap, symmetricResidual},
+	}
+	for _, p := range products {
+		if p.filename == "" {
+			continue
+		}
+		if err := saveProduct(p.img, p.filename, c.highRes, axesWCS, p.cmap, p.symmetric); err != nil {
+			return fmt.Errorf("failed to save image: %v", err)
+		}
+		if !c.writeFITS {
+			continue
+		}
+		fitsFile := strings.TrimSuffix(p.filename, filepath.Ext(p.filename)) + ".fits"
+		meta.BUnit = p.bunit
+		fmt.Printf("Saving FITS image to %s...\n", fitsFile)
+		err := writeFile(fitsFile, func(w io.Writer) error {
+			return clean.WriteFITSImage(w, p.img, c.bitpix, meta)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save FITS image: %v", err)
+		}
+	}
+
+	writers := []struct {
+		filename string
+		write    func(w io.Writer) error
+	}{
+		{c.modFile, func(w io.Writer) error {
+			return clean.WriteDifmapModel(w, result.Components, result.WCS)
+		}},
+		{c.ccFile, func(w io.Writer) error {
+			return clean.WriteAIPSCCTable(w, result.Components, result.WCS)
+		}},
+		{c.jsonFile, func(w io.Writer) error {
+			return clean.WriteComponentsJSON(w, result.Components, result.WCS)
+		}},
+	}
+	for _, cw := range writers {
+		if cw.filename == "" {
+			continue
+		}
+		fmt.Printf("Writing %d components to %s...\n", len(result.Components), cw.filename)
+		if err := writeFile(cw.filename, cw.write); err != nil {
+			return fmt.Errorf("failed to write components: %v", err)
+		}
 	}
 
 	fmt.Println("Done!")
+	return nil
+}
+
+// startProfiles starts CPU profiling to cpuFile, if set, and returns a
+// function that stops it and writes a heap profile to memFile, if set.
+func startProfiles(cpuFile, memFile string) (func(), error) {
+	var cpu *os.File
+	if cpuFile != "" {
+		f, err := os.Create(cpuFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		cpu = f
+	}
+	return func() {
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			cpu.Close()
+		}
+		if memFile == "" {
+			return
+		}
+		f, err := os.Create(memFile)
+		if err != nil {
+			log.Printf("Failed to write heap profile: %v", err)
+			return
+		}
+		defer f.Close()
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			log.Printf("Failed to write heap profile: %v", err)
+		}
+	}, nil
+}
+
+func writeFile(filename string, write func(w io.Writer) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readFITSFile reads one plane of the FITS image in filename.
+func readFITSFile(filename string, plane int) (clean.Image, clean.FITSMetadata, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, clean.FITSMetadata{}, err
+	}
+	defer f.Close()
+	return clean.ReadFITSImage(bufio.NewReader(f), plane)
+}
+
+// loadColormap returns the colormap registered as name, or reads one from
+// name if it is a .csv file.
+func loadColormap(name string) (clean.Continuous, error) {
+	if !strings.EqualFold(filepath.Ext(name), ".csv") {
+		return clean.Colormap(name)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	g, err := clean.LoadColormapCSV(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return g, nil
+}
+
+// buildMask combines the mask flags into a single mask, or returns nil when
+// none is given.
+func buildMask(size int, boxes, circles, regionFile string) (clean.Mask, error) {
+	if boxes == "" && circles == "" && regionFile == "" {
+		return nil, nil
+	}
+	mask := clean.NewMask(size)
+	if regionFile != "" {
+		var err error
+		mask, err = clean.LoadDS9Regions(regionFile, size)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, box := range splitList(boxes) {
+		v, err := parseFloatList(box)
+		if err != nil || len(v) != 4 {
+			return nil, fmt.Errorf("box %q must be x0,y0,x1,y1", box)
+		}
+		mask.SetBox(int(v[0]), int(v[1]), int(v[2]), int(v[3]), true)
+	}
+	for _, circle := range splitList(circles) {
+		v, err := parseFloatList(circle)
+		if err != nil || len(v) != 3 || v[2] <= 0 {
+			return nil, fmt.Errorf("circle %q must be cx,cy,r with r > 0", circle)
+		}
+		mask.SetCircle(v[0], v[1], v[2], true)
+	}
+	if mask.Count() == 0 {
+		return nil, fmt.Errorf("mask excludes every pixel")
+	}
+	fmt.Printf("Clean mask covers %d pixels\n", mask.Count())
+	return mask, nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseFloatList(s string) ([]float64, error) {
+	var values []float64
+	for _, field := range strings.Split(s, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// saveProduct saves img as a PNG coloured by cmap, framed with axes for wcs
+// unless it is nil.
+func saveProduct(img clean.Image, filename string, highRes bool, wcs *clean.WCS, cmap clean.Continuous, symmetric bool) error {
+	outputDir := filepath.Dir(filename)
+	if outputDir != "." && outputDir != "" {
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %v", err)
+		}
+	}
+	if highRes {
+		fmt.Println("Upsampling to 2K resolution...")
+		if wcs != nil {
+			w := upsampledWCS(*wcs, len(img), 2048)
+			wcs = &w
+		}
+		img = upsampleImage(img, 2048, 2048)
+	}
+	fmt.Printf("Saving image to %s...\n", filename)
+	if wcs == nil {
+		return saveImageAsPNG(img, filename, cmap, symmetric)
+	}
+	return writePNG(drawAxes(colorize(img, cmap, symmetric), *wcs), filename)
+}
+
+// upsampledWCS is wcs for an n-pixel image resampled to size pixels by
+// upsampleImage, which maps the first and last pixels onto each other.
+func upsampledWCS(wcs clean.WCS, n, size int) clean.WCS {
+	ratio := float64(size-1) / float64(n-1)
+	wcs.RefX *= ratio
+	wcs.RefY *= ratio
+	wcs.Cell /= clean.Angle(ratio)
+	return wcs
+}
+
+// parseWCS builds the WCS of a size×size image from the sky coordinate
+// flags.
+func parseWCS(size int, cell, ra, dec, projection string) (clean.WCS, error) {
+	var err error
+	wcs := clean.NewWCS(size, size, 0)
+	if wcs.Cell, err = clean.ParseAngle(cell, clean.Milliarcsecond); err != nil {
+		return wcs, fmt.Errorf("-cellsize: %v", err)
+	}
+	if wcs.RA, err = clean.ParseRA(ra); err != nil {
+		return wcs, fmt.Errorf("-ra: %v", err)
+	}
+	if wcs.Dec, err = clean.ParseDec(dec); err != nil {
+		return wcs, fmt.Errorf("-dec: %v", err)
+	}
+	if wcs.Projection, err = clean.ParseProjection(projection); err != nil {
+		return wcs, err
+	}
+	return wcs, wcs.Validate()
 }
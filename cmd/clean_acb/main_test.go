@@ -0,0 +1,458 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mothergoose31/clean"
+	"github.com/mothergoose31/clean/internal/testutil"
+)
+
+func BenchmarkSaveImageAsPNG(b *testing.B) {
+	dir := b.TempDir()
+	for _, n := range []int{64, 128, 256, 512} {
+		img := make(clean.Image, n)
+		for x := range img {
+			img[x] = make([]float64, n)
+			for y := range img[x] {
+				img[x][y] = math.Sin(float64(x)/7) * math.Cos(float64(y)/5)
+			}
+		}
+		filename := filepath.Join(dir, fmt.Sprintf("bench%d.png", n))
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := saveImageAsPNG(img, filename, clean.Viridis, false); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestPNGNorthUp saves a point source 10 mas north-east of the phase centre
+// and checks that it lands up and to the left in the PNG, level with the
+// +10 Dec tick and above the +10 RA tick.
+func TestPNGNorthUp(t *testing.T) {
+	const n = 33
+	wcs := clean.NewWCS(n, n, clean.Milliarcsecond)
+	// Off-centre, so the Dec ticks are not symmetric about the middle row.
+	wcs.RefY = 10
+	img := make(clean.Image, n)
+	for x := range img {
+		img[x] = make([]float64, n)
+	}
+	sx, sy := wcs.Pixel(10*clean.Milliarcsecond, 10*clean.Milliarcsecond)
+	img[int(sx)][int(sy)] = 1
+
+	filename := filepath.Join(t.TempDir(), "ne.png")
+	if err := saveProduct(img, filename, false, &wcs, clean.Viridis, false); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	dst, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peak := clean.NewLUT(clean.Viridis, colormapLUTSize).At(1)
+	var found []image.Point
+	for y := axisMargin; y < axisMargin+n; y++ {
+		for x := axisMargin; x < axisMargin+n; x++ {
+			if r, g, b, _ := dst.At(x, y).RGBA(); r>>8 == uint32(peak.R) && g>>8 == uint32(peak.G) && b>>8 == uint32(peak.B) {
+				found = append(found, image.Pt(x-axisMargin, y-axisMargin))
+			}
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("found the source at %v, want one pixel", found)
+	}
+	src := found[0]
+	if src.X >= n/2 || src.Y >= n/2 {
+		t.Errorf("north-east source at PNG (%d, %d), want the upper-left quadrant", src.X, src.Y)
+	}
+
+	black := func(x, y int) bool {
+		r, g, b, a := dst.At(x, y).RGBA()
+		return r == 0 && g == 0 && b == 0 && a == 0xffff
+	}
+	if !black(axisMargin-2, axisMargin+src.Y) {
+		t.Errorf("no Dec tick level with the source at row %d", src.Y)
+	}
+	if !black(axisMargin+src.X, axisMargin+n+1) {
+		t.Errorf("no RA tick below the source at column %d", src.X)
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	beam := clean.Beam{BMaj: 4, BMin: 2, BPA: 30}
+	for _, tc := range []struct {
+		args  []string
+		err   string // substring of the error, if any
+		check func(t *testing.T, opts clean.Options)
+	}{
+		{args: []string{"-input", "a.acb"}, check: func(t *testing.T, opts clean.Options) {
+			if opts.Gain != 0.1 || opts.MaxIterations != 50 || opts.NumScales != 5 || opts.AutoMaskSigma != 0 {
+				t.Errorf("defaults gave %+v", opts)
+			}
+			if opts.Beam != beam {
+				t.Errorf("beam %v, want %v from the input", opts.Beam, beam)
+			}
+		}},
+		{args: []string{"-input", "a.acb", "-automask", "4.5"}, check: func(t *testing.T, opts clean.Options) {
+			if opts.AutoMaskSigma != 4.5 {
+				t.Errorf("AutoMaskSigma %g, want 4.5", opts.AutoMaskSigma)
+			}
+		}},
+		{args: []string{"-input", "a.acb", "-threshold", "0", "-support-cutoff", "0"}, check: func(t *testing.T, opts clean.Options) {
+			want := clean.SetThreshold | clean.SetSupportCutoff
+			if opts.Threshold != 0 || opts.SupportCutoff != 0 || opts.Set&want != want {
+				t.Errorf("threshold %g, cutoff %g, set %v; want explicit zeros", opts.Threshold, opts.SupportCutoff, opts.Set)
+			}
+		}},
+		{args: []string{"-input", "a.acb", "-scale-sizes", "0, 2,4"}, check: func(t *testing.T, opts clean.Options) {
+			if opts.NumScales != 0 || fmt.Sprint(opts.ScaleSizes) != "[0 2 4]" {
+				t.Errorf("scales %d, sizes %v; want sizes [0 2 4]", opts.NumScales, opts.ScaleSizes)
+			}
+		}},
+		{args: []string{"-input", "a.acb", "-scale-bias", "0.6"}, check: func(t *testing.T, opts clean.Options) {
+			if opts.ScaleBias == nil {
+				t.Error("no scale bias for -scale-bias 0.6")
+			}
+		}},
+		{args: []string{"-input", "a.acb", "-bmaj", "3", "-bpa", "10"}, check: func(t *testing.T, opts clean.Options) {
+			if want := (clean.Beam{BMaj: 3, BMin: 3, BPA: 10}); opts.Beam != want {
+				t.Errorf("beam %v, want %v", opts.Beam, want)
+			}
+		}},
+		{args: []string{"-dirty", "d.fits", "-psf", "p.fits"}},
+		{args: nil, err: "specify an ACB file"},
+		{args: []string{"-input", "a.acb", "-dirty", "d.fits", "-psf", "p.fits"}, err: "specify an ACB file"},
+		{args: []string{"-dirty", "d.fits"}, err: "-dirty and -psf"},
+		{args: []string{"-input", "a.acb", "-gain", "0"}, err: "-gain"},
+		{args: []string{"-input", "a.acb", "-niter", "0"}, err: "-niter"},
+		{args: []string{"-input", "a.acb", "-scales", "0"}, err: "-scales"},
+		{args: []string{"-input", "a.acb", "-bitpix", "16"}, err: "-bitpix"},
+		{args: []string{"-input", "a.acb", "-scale-sizes", "1,x"}, err: "-scale-sizes"},
+		{args: []string{"-input", "a.acb", "-scale-bias", "1"}, err: "-scale-bias"},
+		{args: []string{"-input", "a.acb", "-automask", "-1"}, err: "auto-mask"},
+	} {
+		c, err := parseFlags(tc.args)
+		var opts clean.Options
+		if err == nil {
+			opts, err = c.options(clean.NewWCS(64, 64, clean.Milliarcsecond), beam)
+		}
+		switch {
+		case tc.err != "":
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%q: got error %v, want one containing %q", tc.args, err, tc.err)
+			}
+		case err != nil:
+			t.Errorf("%q: %v", tc.args, err)
+		case tc.check != nil:
+			tc.check(t, opts)
+		}
+	}
+}
+
+func TestBuildMask(t *testing.T) {
+	testutil.Quiet(t)
+	const n = 32
+	regions := filepath.Join(t.TempDir(), "mask.reg")
+	if err := os.WriteFile(regions, []byte("image\ncircle(21,21,3)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := func(set func(m clean.Mask)) clean.Mask {
+		m := clean.NewMask(n)
+		set(m)
+		return m
+	}
+	for _, tc := range []struct {
+		name                    string
+		boxes, circles, regions string
+		want                    clean.Mask
+		err                     bool
+	}{
+		{name: "none"},
+		{name: "box", boxes: "2,3,8,9", want: want(func(m clean.Mask) { m.SetBox(2, 3, 8, 9, true) })},
+		{name: "boxes", boxes: " 2,3,8,9; 20,20,25,22 ;", want: want(func(m clean.Mask) {
+			m.SetBox(2, 3, 8, 9, true)
+			m.SetBox(20, 20, 25, 22, true)
+		})},
+		{name: "circle and box", boxes: "0,0,4,4", circles: "16,16,5.5", want: want(func(m clean.Mask) {
+			m.SetBox(0, 0, 4, 4, true)
+			m.SetCircle(16, 16, 5.5, true)
+		})},
+		{name: "regions and box", boxes: "0,0,4,4", regions: regions, want: want(func(m clean.Mask) {
+			m.SetCircle(20, 20, 3, true)
+			m.SetBox(0, 0, 4, 4, true)
+		})},
+		{name: "short box", boxes: "1,2,3", err: true},
+		{name: "bad box", boxes: "1,2,3,x", err: true},
+		{name: "zero radius", circles: "5,5,0", err: true},
+		{name: "off the image", boxes: "40,40,50,50", err: true},
+		{name: "missing regions", regions: filepath.Join(t.TempDir(), "missing.reg"), err: true},
+	} {
+		mask, err := buildMask(n, tc.boxes, tc.circles, tc.regions)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: no error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if fmt.Sprint(mask) != fmt.Sprint(tc.want) {
+			t.Errorf("%s: got mask of %d pixels, want %d", tc.name, mask.Count(), tc.want.Count())
+		}
+	}
+}
+
+func TestLoadColormap(t *testing.T) {
+	dir := t.TempDir()
+	gray := filepath.Join(dir, "gray.CSV")
+	if err := os.WriteFile(gray, []byte("r,g,b\n0,0,0\n1,1,1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, "bad.csv")
+	if err := os.WriteFile(bad, []byte("0,0,0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name string
+		want clean.Continuous
+		rev  bool // want is sampled at 1-t
+		err  bool
+	}{
+		{name: "viridis", want: clean.Viridis},
+		{name: "Viridis_r", want: clean.Viridis, rev: true},
+		{name: "grayscale_r", want: clean.Grayscale, rev: true},
+		{name: gray, want: clean.Grayscale},
+		{name: "nosuchmap", err: true},
+		{name: "nosuchmap_r", err: true},
+		{name: filepath.Join(dir, "missing.csv"), err: true},
+		{name: bad, err: true},
+	} {
+		c, err := loadColormap(tc.name)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: no error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		for _, v := range []float64{0, 0.25, 0.5, 1} {
+			at := v
+			if tc.rev {
+				at = 1 - v
+			}
+			got, want := rgba(c.ColorAt(v)), rgba(tc.want.ColorAt(at))
+			if got != want {
+				t.Errorf("%s at %g: got %v, want %v", tc.name, v, got, want)
+			}
+		}
+	}
+}
+
+func rgba(c interface{ RGBA() (r, g, b, a uint32) }) [4]uint32 {
+	r, g, b, a := c.RGBA()
+	return [4]uint32{r >> 8, g >> 8, b >> 8, a >> 8}
+}
+
+func TestParseWCS(t *testing.T) {
+	for _, tc := range []struct {
+		cell, ra, dec, projection string
+		want                      clean.Angle
+		err                       bool
+	}{
+		{cell: "1", want: clean.Milliarcsecond},
+		{cell: "0.25", want: 0.25 * clean.Milliarcsecond},
+		{cell: "50uas", want: 50 * clean.Microarcsecond},
+		{cell: "0.002arcsec", want: 2 * clean.Milliarcsecond},
+		{cell: "1", ra: "22:02:43.29", dec: "42:16:39.98", projection: "TAN", want: clean.Milliarcsecond},
+		{cell: "0", err: true},
+		{cell: "-1", err: true},
+		{cell: "1pc", err: true},
+		{cell: "1", ra: "22:x", err: true},
+		{cell: "1", dec: "91", err: true},
+		{cell: "1", projection: "CAR", err: true},
+	} {
+		ra, dec, projection := tc.ra, tc.dec, tc.projection
+		if ra == "" {
+			ra = "0"
+		}
+		if dec == "" {
+			dec = "0"
+		}
+		if projection == "" {
+			projection = "SIN"
+		}
+		wcs, err := parseWCS(64, tc.cell, ra, dec, projection)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%+v: no error", tc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%+v: %v", tc, err)
+			continue
+		}
+		if math.Abs(float64(wcs.Cell-tc.want)) > 1e-9*float64(tc.want) || wcs.RefX != 32 || wcs.RefY != 32 {
+			t.Errorf("%+v: cell %v about (%g, %g), want %v about (32, 32)", tc, wcs.Cell, wcs.RefX, wcs.RefY, tc.want)
+		}
+		if wcs.Projection.String() != projection {
+			t.Errorf("%+v: projection %v", tc, wcs.Projection)
+		}
+	}
+}
+
+// TestUpsampledWCS checks that the corners of the upsampled image keep the
+// offsets of the corners of the original, as upsampleImage maps them onto
+// each other.
+func TestUpsampledWCS(t *testing.T) {
+	for _, tc := range []struct {
+		n, size    int
+		refX, refY float64
+	}{
+		{64, 2048, 32, 32},
+		{256, 2048, 128, 128},
+		{33, 2048, 10, 20.5},
+		{64, 64, 32, 32},
+	} {
+		wcs := clean.NewWCS(tc.n, tc.n, 0.5*clean.Milliarcsecond)
+		wcs.RefX, wcs.RefY = tc.refX, tc.refY
+		up := upsampledWCS(wcs, tc.n, tc.size)
+		for _, corner := range [][2]int{{0, 0}, {1, 1}} {
+			e0, n0 := wcs.Offset(float64(corner[0]*(tc.n-1)), float64(corner[1]*(tc.n-1)))
+			e1, n1 := up.Offset(float64(corner[0]*(tc.size-1)), float64(corner[1]*(tc.size-1)))
+			if math.Abs(float64(e1-e0)) > 1e-9*float64(wcs.Cell) || math.Abs(float64(n1-n0)) > 1e-9*float64(wcs.Cell) {
+				t.Errorf("%+v corner %v: offset (%v, %v), want (%v, %v)", tc, corner, e1, n1, e0, n0)
+			}
+		}
+	}
+}
+
+// writeTestFITS writes img to a FITS file in dir placed by wcs.
+func writeTestFITS(t *testing.T, dir, name string, img clean.Image, wcs clean.WCS) string {
+	t.Helper()
+	filename := filepath.Join(dir, name)
+	err := writeFile(filename, func(w io.Writer) error {
+		return clean.WriteFITSImage(w, img, -64, clean.FITSMetadata{WCS: wcs})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// TestRun cleans a FITS pair holding two point sources with a clean box
+// around one of them, and checks the files written.
+func TestRun(t *testing.T) {
+	testutil.Quiet(t)
+	const n = 32
+	gaussian := func(x0, y0 int) clean.Image {
+		img := make(clean.Image, n)
+		for x := range img {
+			img[x] = make([]float64, n)
+			for y := range img[x] {
+				dx, dy := float64(x-x0), float64(y-y0)
+				img[x][y] = math.Exp(-(dx*dx + dy*dy) / (2 * 1.5 * 1.5))
+			}
+		}
+		return img
+	}
+	psf := gaussian(n/2, n/2)
+	dirty := gaussian(8, 24)
+	for x, row := range gaussian(24, 8) {
+		for y, v := range row {
+			dirty[x][y] += 0.5 * v
+		}
+	}
+	wcs := clean.NewWCS(n, n, 2*clean.Milliarcsecond)
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out", "restored.png")
+	components := filepath.Join(dir, "components.json")
+	err := run([]string{
+		"-dirty", writeTestFITS(t, dir, "dirty.fits", dirty, wcs),
+		"-psf", writeTestFITS(t, dir, "psf.fits", psf, wcs),
+		"-algorithm", "hogbom",
+		"-niter", "100",
+		"-gain", "0.5",
+		"-threshold", "1e-3",
+		"-mask-box", "4,20,12,28",
+		"-cellsize", "7", // ignored, as the FITS header has a cell size
+		"-output", out,
+		"-fits",
+		"-axes",
+		"-components-json", components,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cfg, err := png.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != n+2*axisMargin || cfg.Height != n+2*axisMargin {
+		t.Errorf("PNG is %dx%d, want %[3]dx%[3]d with axes", cfg.Width, cfg.Height, n+2*axisMargin)
+	}
+
+	restored, meta, err := readFITSFile(strings.TrimSuffix(out, ".png")+".fits", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != n || math.Abs(float64(meta.WCS.Cell/wcs.Cell)-1) > 1e-9 || meta.BUnit != "JY/BEAM" {
+		t.Errorf("restored FITS is %d pixels of %v in %s, want %d of %v in JY/BEAM", len(restored), meta.WCS.Cell, meta.BUnit, n, wcs.Cell)
+	}
+
+	data, err := os.ReadFile(components)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var comps []struct {
+		X, Y  int
+		Flux  float64
+		North float64 `json:"north_mas"`
+	}
+	if err := json.Unmarshal(data, &comps); err != nil {
+		t.Fatal(err)
+	}
+	var flux float64
+	for _, c := range comps {
+		if c.X < 4 || c.X > 12 || c.Y < 20 || c.Y > 28 {
+			t.Errorf("component at (%d, %d) is outside the clean box", c.X, c.Y)
+		}
+		if want := float64(c.Y-n/2) * 2; math.Abs(c.North-want) > 1e-9 {
+			t.Errorf("component at y = %d is %g mas north, want %g with 2 mas cells", c.Y, c.North, want)
+		}
+		flux += c.Flux
+	}
+	if math.Abs(flux-1) > 0.01 {
+		t.Errorf("components in the clean box sum to %g, want 1", flux)
+	}
+}
@@ -0,0 +1,189 @@
+package clean
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxColormapStops bounds the stops LoadColormapCSV reads.
+const maxColormapStops = 4096
+
+// reversedSuffix appended to a registered name selects the reversed map, as
+// in Matplotlib.
+const reversedSuffix = "_r"
+
+// Grayscale runs from black to white.
+var Grayscale Continuous = RGBGradient{
+	Colors: []color.RGBA{{0, 0, 0, 255}, {255, 255, 255, 255}},
+}
+
+// RdBu is the 11-class ColorBrewer diverging map from dark red through white
+// to dark blue. Centred on zero it shows negative residuals in red and
+// positive ones in blue.
+var RdBu Continuous = RGBGradient{
+	Colors: []color.RGBA{
+		{103, 0, 31, 255},
+		{178, 24, 43, 255},
+		{214, 96, 77, 255},
+		{244, 165, 130, 255},
+		{253, 219, 199, 255},
+		{247, 247, 247, 255},
+		{209, 229, 240, 255},
+		{146, 197, 222, 255},
+		{67, 147, 195, 255},
+		{33, 102, 172, 255},
+		{5, 48, 97, 255},
+	},
+}
+
+var (
+	colormapMu sync.RWMutex
+	colormaps  = make(map[string]Continuous)
+)
+
+// RegisterColormap makes c available by name to Colormap and the
+// command-line tool. Names are case-insensitive. It panics if name is
+// already registered or ends in "_r".
+func RegisterColormap(name string, c Continuous) {
+	name = strings.ToLower(name)
+	if strings.HasSuffix(name, reversedSuffix) {
+		panic("clean: RegisterColormap called with reversed name " + name)
+	}
+	colormapMu.Lock()
+	defer colormapMu.Unlock()
+	if _, dup := colormaps[name]; dup {
+		panic("clean: RegisterColormap called twice for colormap " + name)
+	}
+	colormaps[name] = c
+}
+
+// Colormap returns the colormap registered as name, or its reverse if name
+// is a registered name followed by "_r".
+func Colormap(name string) (Continuous, error) {
+	key := strings.ToLower(name)
+	base, rev := strings.CutSuffix(key, reversedSuffix)
+	colormapMu.RLock()
+	c, ok := colormaps[base]
+	colormapMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown colormap %q", name)
+	}
+	if rev {
+		return Reverse(c), nil
+	}
+	return c, nil
+}
+
+// Colormaps returns the sorted names of the registered colormaps.
+func Colormaps() []string {
+	colormapMu.RLock()
+	defer colormapMu.RUnlock()
+	names := make([]string, 0, len(colormaps))
+	for name := range colormaps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Reverse returns c run from its end to its start.
+func Reverse(c Continuous) Continuous {
+	if r, ok := c.(reversed); ok {
+		return r.c
+	}
+	return reversed{c}
+}
+
+type reversed struct {
+	c Continuous
+}
+
+func (r reversed) ColorAt(t float64) color.Color {
+	return r.c.ColorAt(1 - t)
+}
+
+// LoadColormapCSV reads a colormap from evenly spaced RGB stops, one
+// "r,g,b" record per line from the low end of the map to the high end.
+// Channels are 0-255, or fractions in [0, 1] if no value in the file
+// exceeds 1. Blank lines, lines starting with '#' and a non-numeric header
+// record are skipped.
+func LoadColormapCSV(r io.Reader) (RGBGradient, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var stops [][3]float64
+	fractions := true
+	for first := true; ; first = false {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return RGBGradient{}, err
+		}
+		line, _ := cr.FieldPos(0)
+		if len(record) != 3 {
+			return RGBGradient{}, fmt.Errorf("colormap line %d has %d fields, want r,g,b", line, len(record))
+		}
+		stop, ok := parseStop(record)
+		if !ok {
+			if first {
+				continue
+			}
+			return RGBGradient{}, fmt.Errorf("colormap line %d: bad stop %q", line, strings.Join(record, ","))
+		}
+		for _, v := range stop {
+			if v < 0 || v > 255 {
+				return RGBGradient{}, fmt.Errorf("colormap line %d: channel %g outside 0-255", line, v)
+			}
+			fractions = fractions && v <= 1
+		}
+		if len(stops) == maxColormapStops {
+			return RGBGradient{}, fmt.Errorf("colormap has more than %d stops", maxColormapStops)
+		}
+		stops = append(stops, stop)
+	}
+	if len(stops) < 2 {
+		return RGBGradient{}, fmt.Errorf("colormap has %d stops, want at least 2", len(stops))
+	}
+
+	scale := 1.0
+	if fractions {
+		scale = 255
+	}
+	g := RGBGradient{Colors: make([]color.RGBA, len(stops))}
+	for i, s := range stops {
+		g.Colors[i] = color.RGBA{
+			R: uint8(s[0]*scale + 0.5),
+			G: uint8(s[1]*scale + 0.5),
+			B: uint8(s[2]*scale + 0.5),
+			A: 255,
+		}
+	}
+	return g, nil
+}
+
+func parseStop(record []string) ([3]float64, bool) {
+	var stop [3]float64
+	for i, field := range record {
+		v, err := parseFinite(strings.TrimSpace(field))
+		if err != nil {
+			return stop, false
+		}
+		stop[i] = v
+	}
+	return stop, true
+}
+
+func init() {
+	RegisterColormap("grayscale", Grayscale)
+	RegisterColormap("rdbu", RdBu)
+}
@@ -0,0 +1,209 @@
+package clean
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden colormaps in testdata")
+
+func TestColormapRegistry(t *testing.T) {
+	want := []string{"cividis", "grayscale", "inferno", "magma", "plasma", "rdbu", "viridis"}
+	if got := Colormaps(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Colormaps() = %v, want %v", got, want)
+	}
+	for _, name := range want {
+		c, err := Colormap(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := Colormap(name + "_r")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range []float64{0, 1} {
+			if got, want := r.ColorAt(tt), c.ColorAt(1-tt); got != want {
+				t.Errorf("%s_r at %g is %v, want %v", name, tt, got, want)
+			}
+		}
+		if g, ok := c.(RGBGradient); ok && name != "grayscale" && name != "rdbu" && len(g.Colors) != 256 {
+			t.Errorf("%s has %d stops, want 256", name, len(g.Colors))
+		}
+	}
+	if c, _ := Colormap("RdBu"); c.ColorAt(0) != (color.RGBA{103, 0, 31, 255}) {
+		t.Errorf("RdBu starts at %v", c.ColorAt(0))
+	}
+	if !reflect.DeepEqual(Reverse(Reverse(Magma)), Magma) {
+		t.Error("reversing twice does not restore the map")
+	}
+	if _, err := Colormap("jet"); err == nil {
+		t.Error("unknown colormap accepted")
+	}
+}
+
+func TestLoadColormapCSV(t *testing.T) {
+	g, err := LoadColormapCSV(strings.NewReader("r,g,b\n# fractions\n0, 0, 0.5\n\n1,0.25,0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []color.RGBA{{0, 0, 128, 255}, {255, 64, 0, 255}}
+	if !reflect.DeepEqual(g.Colors, want) {
+		t.Errorf("fractional stops %v, want %v", g.Colors, want)
+	}
+
+	g, err = LoadColormapCSV(strings.NewReader("0,1,2\n255,128,1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []color.RGBA{{0, 1, 2, 255}, {255, 128, 1, 255}}
+	if !reflect.DeepEqual(g.Colors, want) {
+		t.Errorf("0-255 stops %v, want %v", g.Colors, want)
+	}
+
+	for _, bad := range []string{
+		"",
+		"0,0,0\n",
+		"0,0,0\n1,1\n",
+		"0,0,0\nred,0,0\n",
+		"0,0,0\n256,0,0\n",
+		"0,0,0\n-1,0,0\n",
+		"0,0,0\nNaN,0,0\n",
+		strings.Repeat("1,2,3\n", maxColormapStops+1),
+	} {
+		if _, err := LoadColormapCSV(strings.NewReader(bad)); err == nil {
+			t.Errorf("LoadColormapCSV accepted %.40q", bad)
+		}
+	}
+}
+
+// TestColormapGolden samples every registered gradient at each stop and
+// halfway between stops and compares the colours with
+// testdata/colormap_<name>.golden. Run with -update after an intended change.
+func TestColormapGolden(t *testing.T) {
+	for _, name := range Colormaps() {
+		t.Run(name, func(t *testing.T) {
+			c, _ := Colormap(name)
+			g := c.(RGBGradient)
+			n := len(g.Colors)
+			var b strings.Builder
+			for i := 0; i < 2*n-1; i++ {
+				tt := float64(i) / float64(2*(n-1))
+				got := g.ColorAt(tt).(color.RGBA)
+				if i%2 == 0 && got != g.Colors[i/2] {
+					t.Errorf("stop %d is %v, want %v", i/2, got, g.Colors[i/2])
+				}
+				if i%2 == 1 {
+					lo, hi := g.Colors[i/2], g.Colors[i/2+1]
+					if !between(got.R, lo.R, hi.R) || !between(got.G, lo.G, hi.G) || !between(got.B, lo.B, hi.B) {
+						t.Errorf("%v halfway from %v to %v", got, lo, hi)
+					}
+				}
+				fmt.Fprintf(&b, "%.6f %d %d %d %d\n", tt, got.R, got.G, got.B, got.A)
+			}
+
+			golden := filepath.Join("testdata", "colormap_"+name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(b.String()), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			gotLines, wantLines := strings.Split(b.String(), "\n"), strings.Split(string(want), "\n")
+			if len(gotLines) != len(wantLines) {
+				t.Fatalf("%d samples, golden has %d", len(gotLines)-1, len(wantLines)-1)
+			}
+			for i := range wantLines {
+				if gotLines[i] != wantLines[i] {
+					t.Fatalf("sample %d is %q, golden %q", i, gotLines[i], wantLines[i])
+				}
+			}
+		})
+	}
+}
+
+// between reports whether v lies between a and b, in either order.
+func between(v, a, b uint8) bool {
+	return (a <= v && v <= b) || (b <= v && v <= a)
+}
+
+func TestRGBGradientInterpolation(t *testing.T) {
+	// The blue channel of Viridis falls from 37 at the last stop; halfway
+	// down the final interval it must be 36, not a wrapped-around value.
+	g := Viridis.(RGBGradient)
+	n := len(g.Colors)
+	if got, want := g.ColorAt((float64(n)-1.5)/float64(n-1)), (color.RGBA{252, 231, 36, 255}); got != want {
+		t.Errorf("Viridis near the top is %v, want %v", got, want)
+	}
+
+	bw := RGBGradient{Colors: []color.RGBA{{0, 0, 0, 255}, {255, 255, 255, 255}}}
+	if got := bw.ColorAt(0.5); got != (color.RGBA{128, 128, 128, 255}) {
+		t.Errorf("sRGB midpoint of black and white is %v", got)
+	}
+	for _, space := range []ColorSpace{SRGB, Oklab, CIELAB} {
+		bw.Space = space
+		for _, tt := range []float64{math.NaN(), -1, 0, 1, 2} {
+			want := bw.Colors[0]
+			if tt >= 1 {
+				want = bw.Colors[1]
+			}
+			if got := bw.ColorAt(tt); got != want {
+				t.Errorf("%v: ColorAt(%g) = %v, want %v", space, tt, got, want)
+			}
+		}
+	}
+	// Perceptual midpoints of black and white are darker than the sRGB one:
+	// Oklab L = 0.5 and L* = 50 are both about 46% grey.
+	for space, want := range map[ColorSpace]uint8{Oklab: 99, CIELAB: 119} {
+		bw.Space = space
+		if got := bw.ColorAt(0.5).(color.RGBA); got.R != want || got.G != want || got.B != want {
+			t.Errorf("%v midpoint of black and white is %v, want grey %d", space, got, want)
+		}
+	}
+
+	// Every 8-bit colour survives a round trip through each space.
+	for _, space := range []ColorSpace{Oklab, CIELAB} {
+		for r := 0; r < 256; r += 15 {
+			for g := 0; g < 256; g += 5 {
+				for b := 0; b < 256; b += 3 {
+					c := color.RGBA{uint8(r), uint8(g), uint8(b), 255}
+					v := space.toRGB(space.fromRGB(c))
+					if got := (color.RGBA{roundChannel(v[0]), roundChannel(v[1]), roundChannel(v[2]), 255}); got != c {
+						t.Fatalf("%v: %v round trips to %v", space, c, got)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestLUT(t *testing.T) {
+	g := Magma.(RGBGradient)
+	lut := NewLUT(g, len(g.Colors))
+	if !reflect.DeepEqual([]color.RGBA(lut), g.Colors) {
+		t.Error("LUT with one entry per stop differs from the stops")
+	}
+	lut = NewLUT(g, 4096)
+	for i := 0; i <= 1000; i++ {
+		tt := float64(i) / 1000
+		got, want := lut.At(tt), g.ColorAt(tt).(color.RGBA)
+		for _, d := range []int{int(got.R) - int(want.R), int(got.G) - int(want.G), int(got.B) - int(want.B)} {
+			if d < -1 || d > 1 {
+				t.Fatalf("LUT at %g is %v, gradient %v", tt, got, want)
+			}
+		}
+	}
+	if lut.At(math.NaN()) != lut[0] || lut.At(-1) != lut[0] || lut.At(2) != lut[len(lut)-1] {
+		t.Error("LUT does not clamp out-of-range values")
+	}
+}
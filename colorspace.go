@@ -0,0 +1,170 @@
+package clean
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// ColorSpace selects the space in which an RGBGradient interpolates between
+// its stops.
+type ColorSpace int
+
+const (
+	// SRGB interpolates the gamma-encoded channels, as Matplotlib does.
+	SRGB ColorSpace = iota
+	// Oklab interpolates in Björn Ottosson's Oklab space, in which equal
+	// steps look about equally different.
+	Oklab
+	// CIELAB interpolates in CIE L*a*b* with a D65 white point.
+	CIELAB
+)
+
+func (s ColorSpace) String() string {
+	switch s {
+	case SRGB:
+		return "sRGB"
+	case Oklab:
+		return "Oklab"
+	case CIELAB:
+		return "CIELAB"
+	}
+	return fmt.Sprintf("ColorSpace(%d)", int(s))
+}
+
+// fromRGB returns the coordinates of c in s.
+func (s ColorSpace) fromRGB(c color.RGBA) [3]float64 {
+	rgb := [3]float64{float64(c.R), float64(c.G), float64(c.B)}
+	switch s {
+	case Oklab:
+		return linearToOklab(srgbToLinear(rgb))
+	case CIELAB:
+		return xyzToLab(mul(linearToXYZ, srgbToLinear(rgb)))
+	}
+	return rgb
+}
+
+// toRGB is the inverse of fromRGB, returning unclamped 0-255 channels.
+func (s ColorSpace) toRGB(v [3]float64) [3]float64 {
+	switch s {
+	case Oklab:
+		return linearToSRGB(oklabToLinear(v))
+	case CIELAB:
+		return linearToSRGB(mul(xyzToLinear, labToXYZ(v)))
+	}
+	return v
+}
+
+// srgbToLinear decodes 0-255 sRGB channels to linear light in [0, 1].
+func srgbToLinear(rgb [3]float64) [3]float64 {
+	for i, c := range rgb {
+		c /= 255
+		if c <= 0.04045 {
+			rgb[i] = c / 12.92
+		} else {
+			rgb[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+	return rgb
+}
+
+// linearToSRGB is the inverse of srgbToLinear.
+func linearToSRGB(lin [3]float64) [3]float64 {
+	for i, c := range lin {
+		if c <= 0.0031308 {
+			c *= 12.92
+		} else {
+			c = 1.055*math.Pow(c, 1/2.4) - 0.055
+		}
+		lin[i] = 255 * c
+	}
+	return lin
+}
+
+type matrix [3][3]float64
+
+func mul(m matrix, v [3]float64) [3]float64 {
+	return [3]float64{dot(m[0], v), dot(m[1], v), dot(m[2], v)}
+}
+
+var (
+	linearToLMS = matrix{
+		{0.4122214708, 0.5363325363, 0.0514459929},
+		{0.2119034982, 0.6806995451, 0.1073969566},
+		{0.0883024619, 0.2817188376, 0.6299787005},
+	}
+	lmsToOklab = matrix{
+		{0.2104542553, 0.7936177850, -0.0040720468},
+		{1.9779984951, -2.4285922050, 0.4505937099},
+		{0.0259040371, 0.7827717662, -0.8086757660},
+	}
+	oklabToLMS = matrix{
+		{1, 0.3963377774, 0.2158037573},
+		{1, -0.1055613458, -0.0638541728},
+		{1, -0.0894841775, -1.2914855480},
+	}
+	lmsToLinear = matrix{
+		{4.0767416621, -3.3077115913, 0.2309699292},
+		{-1.2684380046, 2.6097574011, -0.3413193965},
+		{-0.0041960863, -0.7034186147, 1.7076147010},
+	}
+
+	linearToXYZ = matrix{
+		{0.4124564, 0.3575761, 0.1804375},
+		{0.2126729, 0.7151522, 0.0721750},
+		{0.0193339, 0.1191920, 0.9503041},
+	}
+	xyzToLinear = matrix{
+		{3.2404542, -1.5371385, -0.4985314},
+		{-0.9692660, 1.8760108, 0.0415560},
+		{0.0556434, -0.2040259, 1.0572252},
+	}
+	// d65 is the white point of sRGB in XYZ.
+	d65 = [3]float64{0.95047, 1, 1.08883}
+)
+
+func linearToOklab(lin [3]float64) [3]float64 {
+	lms := mul(linearToLMS, lin)
+	for i := range lms {
+		lms[i] = math.Cbrt(lms[i])
+	}
+	return mul(lmsToOklab, lms)
+}
+
+func oklabToLinear(lab [3]float64) [3]float64 {
+	lms := mul(oklabToLMS, lab)
+	for i := range lms {
+		lms[i] *= lms[i] * lms[i]
+	}
+	return mul(lmsToLinear, lms)
+}
+
+// labDelta is the knee of the CIELAB companding function.
+const labDelta = 6.0 / 29
+
+func xyzToLab(xyz [3]float64) [3]float64 {
+	var f [3]float64
+	for i := range f {
+		t := xyz[i] / d65[i]
+		if t > labDelta*labDelta*labDelta {
+			f[i] = math.Cbrt(t)
+		} else {
+			f[i] = t/(3*labDelta*labDelta) + 4.0/29
+		}
+	}
+	return [3]float64{116*f[1] - 16, 500 * (f[0] - f[1]), 200 * (f[1] - f[2])}
+}
+
+func labToXYZ(lab [3]float64) [3]float64 {
+	fy := (lab[0] + 16) / 116
+	f := [3]float64{fy + lab[1]/500, fy, fy - lab[2]/200}
+	var xyz [3]float64
+	for i, t := range f {
+		if t > labDelta {
+			xyz[i] = d65[i] * t * t * t
+		} else {
+			xyz[i] = d65[i] * 3 * labDelta * labDelta * (t - 4.0/29)
+		}
+	}
+	return xyz
+}
@@ -0,0 +1,221 @@
+package clean
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Component positions are exported as offsets from the phase centre of a
+// WCS, with north along +y and east along -x.
+
+// componentOffset returns the east and north offset of c from the phase
+// centre in milliarcseconds.
+func componentOffset(c Component, wcs WCS) (float64, float64) {
+	east, north := wcs.Offset(float64(c.X), float64(c.Y))
+	return east.Milliarcseconds(), north.Milliarcseconds()
+}
+
+// WriteDifmapModel writes comps as a Difmap model file. Components with a
+// non-zero Size are written as circular Gaussians.
+func WriteDifmapModel(w io.Writer, comps []Component, wcs WCS) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "! Flux (Jy) Radius (mas)  Theta (deg)  Major (mas)  Axial ratio   Phi (deg) T")
+	for _, c := range comps {
+		east, north := componentOffset(c, wcs)
+		radius := math.Hypot(east, north)
+		theta := math.Atan2(east, north) * 180 / math.Pi
+		if c.Size == 0 {
+			fmt.Fprintf(bw, "%12.6gv %12.6gv %12.6gv\n", c.Flux, radius, theta)
+			continue
+		}
+		major := fwhmPerSigma * c.Size * wcs.Cell.Milliarcseconds()
+		fmt.Fprintf(bw, "%12.6gv %12.6gv %12.6gv %12.6gv %12.6g %12.6g 1\n", c.Flux, radius, theta, major, 1.0, 0.0)
+	}
+	return bw.Flush()
+}
+
+// ReadDifmapModel reads a Difmap model file and converts each component back
+// to the nearest pixel of a size×size image placed on the sky by wcs.
+// Elliptical Gaussians are read as circular ones with the geometric mean of
+// their axes.
+func ReadDifmapModel(r io.Reader, size int, wcs WCS) ([]Component, error) {
+	if err := wcs.Validate(); err != nil {
+		return nil, err
+	}
+	var comps []Component
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "!") {
+			continue
+		}
+		var v []float64
+		for _, field := range strings.Fields(line) {
+			f, err := parseFinite(strings.TrimSuffix(field, "v"))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			v = append(v, f)
+		}
+		if len(v) != 3 && len(v) < 6 {
+			return nil, fmt.Errorf("line %d: want 3 or at least 6 values, got %d", lineNum, len(v))
+		}
+
+		theta := v[2] * math.Pi / 180
+		east := v[1] * math.Sin(theta)
+		north := v[1] * math.Cos(theta)
+		// Check the position before converting it, as converting an
+		// out-of-range float to int is undefined.
+		x, y := wcs.Pixel(Angle(east)*Milliarcsecond, Angle(north)*Milliarcsecond)
+		x, y = math.Round(x), math.Round(y)
+		if !(x >= 0 && x < float64(size) && y >= 0 && y < float64(size)) {
+			return nil, fmt.Errorf("line %d: component lies outside the image", lineNum)
+		}
+		c := Component{X: int(x), Y: int(y), Flux: v[0]}
+		if len(v) >= 6 && v[3] > 0 {
+			if v[4] <= 0 {
+				return nil, fmt.Errorf("line %d: axial ratio %v must be positive", lineNum, v[4])
+			}
+			c.Size = v[3] * math.Sqrt(v[4]) / fwhmPerSigma / wcs.Cell.Milliarcseconds()
+			if math.IsInf(c.Size, 0) {
+				return nil, fmt.Errorf("line %d: component size overflows", lineNum)
+			}
+		}
+		comps = append(comps, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning model file: %v", err)
+	}
+
+	return comps, nil
+}
+
+// skyComponent is a Component with its position on the sky.
+type skyComponent struct {
+	Component
+	East  float64 `json:"east_mas"`
+	North float64 `json:"north_mas"`
+	RA    float64 `json:"ra_deg"`
+	Dec   float64 `json:"dec_deg"`
+}
+
+// WriteComponentsJSON writes comps as a JSON array, adding to each component
+// its offset from the phase centre and its RA and Dec according to wcs.
+func WriteComponentsJSON(w io.Writer, comps []Component, wcs WCS) error {
+	out := make([]skyComponent, len(comps))
+	for i, c := range comps {
+		out[i].Component = c
+		out[i].East, out[i].North = componentOffset(c, wcs)
+		ra, dec, _ := wcs.PixelToSky(float64(c.X), float64(c.Y))
+		out[i].RA, out[i].Dec = ra.Degrees(), dec.Degrees()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// WriteAIPSCCTable writes comps as a FITS file holding an empty primary HDU
+// and an AIPS CC binary table, as read by AIPS, Difmap and CASA.
+func WriteAIPSCCTable(w io.Writer, comps []Component, wcs WCS) error {
+	var primary fitsHeader
+	primary.bool("SIMPLE", true, "conforms to FITS standard")
+	primary.int("BITPIX", 8, "")
+	primary.int("NAXIS", 0, "")
+	primary.bool("EXTEND", true, "")
+	if err := primary.writeTo(w); err != nil {
+		return err
+	}
+	return writeAIPSCCExtension(w, comps, wcs)
+}
+
+func writeAIPSCCExtension(w io.Writer, comps []Component, wcs WCS) error {
+	columns := []struct{ name, unit string }{
+		{"FLUX", "JY"},
+		{"DELTAX", "DEGREES"},
+		{"DELTAY", "DEGREES"},
+		{"MAJOR AX", "DEGREES"},
+		{"MINOR AX", "DEGREES"},
+		{"POSANGLE", "DEGREES"},
+		{"TYPE OBJ", "CODE"},
+	}
+
+	var h fitsHeader
+	h.string("XTENSION", "BINTABLE", "binary table extension")
+	h.int("BITPIX", 8, "")
+	h.int("NAXIS", 2, "")
+	h.int("NAXIS1", 4*len(columns), "bytes per row")
+	h.int("NAXIS2", len(comps), "number of components")
+	h.int("PCOUNT", 0, "")
+	h.int("GCOUNT", 1, "")
+	h.int("TFIELDS", len(columns), "")
+	for i, col := range columns {
+		n := strconv.Itoa(i + 1)
+		h.string("TTYPE"+n, col.name, "")
+		h.string("TFORM"+n, "1E", "")
+		h.string("TUNIT"+n, col.unit, "")
+	}
+	h.string("EXTNAME", "AIPS CC", "")
+	h.int("EXTVER", 1, "")
+	if err := h.writeTo(w); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, c := range comps {
+		east, north := wcs.Offset(float64(c.X), float64(c.Y))
+		major := fwhmPerSigma * c.Size * wcs.Cell.Degrees()
+		objType := 0.0
+		if c.Size > 0 {
+			objType = 1
+		}
+		row := []float32{
+			float32(c.Flux),
+			float32(east.Degrees()),
+			float32(north.Degrees()),
+			float32(major),
+			float32(major),
+			0,
+			float32(objType),
+		}
+		if err := binary.Write(&buf, binary.BigEndian, row); err != nil {
+			return err
+		}
+	}
+	return writeFITSBlocks(w, buf.Bytes(), 0)
+}
+
+// startModel builds the model described by comps and the residual left
+// after subtracting it from dirty. psf is the (2n-1)×(2n-1) padded PSF.
+func startModel(dirty, psf Image, comps []Component) (Image, Grid) {
+	n := len(dirty)
+	model := newImage(n, n)
+	for _, c := range comps {
+		if c.Size == 0 {
+			model[c.X][c.Y] += c.Flux
+			continue
+		}
+		shape := createBasisFunctionsFromACB([]float64{c.Size}, n)[0]
+		addShifted(model, shape, Point{x: c.X, y: c.Y}, c.Flux)
+	}
+	residual := GridFromImage(dirty)
+	if len(comps) > 0 {
+		fmt.Printf("Subtracting %d starting model components...\n", len(comps))
+		predicted := convolveSame(model, psf)
+		for i, row := range predicted {
+			dst := residual.Row(i)
+			for j, v := range row {
+				dst[j] -= v
+			}
+		}
+	}
+	return model, residual
+}
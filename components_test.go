@@ -0,0 +1,139 @@
+package clean
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var testComponents = []Component{
+	{X: 32, Y: 32, Flux: 1.25, Iteration: 1},
+	{X: 10, Y: 50, Flux: -0.125, Iteration: 2},
+	{X: 45, Y: 20, Scale: 2, Size: 3, Flux: 0.5, Iteration: 3},
+	{X: 0, Y: 63, Scale: 1, Size: 1.5, Flux: 2e-4, Iteration: 4},
+}
+
+func testComponentWCS() WCS {
+	wcs := NewWCS(64, 64, 20*Microarcsecond)
+	wcs.RA = 330.68 * Degree
+	wcs.Dec = 42.28 * Degree
+	return wcs
+}
+
+func TestDifmapModelRoundTrip(t *testing.T) {
+	wcs := testComponentWCS()
+	var buf bytes.Buffer
+	if err := WriteDifmapModel(&buf, testComponents, wcs); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadDifmapModel(&buf, 64, wcs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(testComponents) {
+		t.Fatalf("read %d components, wrote %d", len(got), len(testComponents))
+	}
+	for i, c := range got {
+		want := testComponents[i]
+		if c.X != want.X || c.Y != want.Y ||
+			math.Abs(c.Flux-want.Flux) > 1e-5*math.Abs(want.Flux) || math.Abs(c.Size-want.Size) > 1e-5*want.Size {
+			t.Errorf("component %d read back as %+v, wrote %+v", i, c, want)
+		}
+	}
+}
+
+func TestComponentsJSONRoundTrip(t *testing.T) {
+	wcs := testComponentWCS()
+	var buf bytes.Buffer
+	if err := WriteComponentsJSON(&buf, testComponents, wcs); err != nil {
+		t.Fatal(err)
+	}
+	var got []skyComponent
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(testComponents) {
+		t.Fatalf("read %d components, wrote %d", len(got), len(testComponents))
+	}
+	for i, c := range got {
+		if c.Component != testComponents[i] {
+			t.Errorf("component %d read back as %+v, wrote %+v", i, c.Component, testComponents[i])
+		}
+		east, north := componentOffset(c.Component, wcs)
+		x, y, ok := wcs.SkyToPixel(Angle(c.RA)*Degree, Angle(c.Dec)*Degree)
+		if c.East != east || c.North != north || !ok || math.Abs(x-float64(c.X)) > 1e-4 || math.Abs(y-float64(c.Y)) > 1e-4 {
+			t.Errorf("component %d at offset (%g, %g) mas, RA/Dec pixel (%g, %g); want (%g, %g) mas and (%d, %d)",
+				i, c.East, c.North, x, y, east, north, c.X, c.Y)
+		}
+	}
+}
+
+func TestAIPSCCTableRoundTrip(t *testing.T) {
+	wcs := testComponentWCS()
+	var buf bytes.Buffer
+	if err := WriteAIPSCCTable(&buf, testComponents, wcs); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len()%fitsBlockSize != 0 {
+		t.Fatalf("file is %d bytes, not a whole number of blocks", buf.Len())
+	}
+	r := bytes.NewReader(buf.Bytes())
+	primary, err := readFITSHeader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primary["SIMPLE"] != "T" || primary["NAXIS"] != "0" || primary["EXTEND"] != "T" {
+		t.Errorf("primary header %v", primary)
+	}
+	table, err := readFITSHeader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table["XTENSION"] != "BINTABLE" || table["EXTNAME"] != "AIPS CC" || table["NAXIS1"] != "28" ||
+		table["NAXIS2"] != strconv.Itoa(len(testComponents)) || table["TTYPE1"] != "FLUX" {
+		t.Fatalf("table header %v", table)
+	}
+
+	rows := make([][7]float32, len(testComponents))
+	if err := binary.Read(r, binary.BigEndian, rows); err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range rows {
+		c := testComponents[i]
+		east, north := wcs.Offset(float64(c.X), float64(c.Y))
+		major := fwhmPerSigma * c.Size * wcs.Cell.Degrees()
+		want := [7]float64{c.Flux, east.Degrees(), north.Degrees(), major, major, 0, 0}
+		if c.Size > 0 {
+			want[6] = 1
+		}
+		for k, v := range row {
+			if math.Abs(float64(v)-want[k]) > 1e-6*math.Abs(want[k]) {
+				t.Errorf("component %d column %d is %g, want %g", i, k+1, v, want[k])
+			}
+		}
+	}
+}
+
+func TestStartModelOutsideImage(t *testing.T) {
+	dirty, psf := pointSky(32, map[Point]float64{{16, 16}: 1})
+	for _, comps := range [][]Component{
+		{{X: 40, Y: 3, Flux: 1}},
+		{{X: 3, Y: 32, Flux: 1}},
+		{{X: -1, Y: 3, Flux: 1}},
+	} {
+		for _, name := range Deconvolvers() {
+			d, err := NewDeconvolver(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = d.Deconvolve(dirty, psf, nil, Options{StartModel: comps})
+			if err == nil || !strings.Contains(err.Error(), "starting model component") {
+				t.Errorf("%s: start model %+v gave error %v", name, comps, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package clean
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Component is a single CLEAN component: Flux placed at pixel (X, Y) with the
+// shape of scale Scale by iteration Iteration (counted from 1; 0 for
+// components of a starting model). Size is the Gaussian sigma of the shape in
+// pixels, zero for a point. Point-source deconvolvers always use scale 0 and
+// size 0.
+type Component struct {
+	X         int     `json:"x"`
+	Y         int     `json:"y"`
+	Scale     int     `json:"scale"`
+	Size      float64 `json:"size"`
+	Flux      float64 `json:"flux"`
+	Iteration int     `json:"iteration"`
+}
+
+// Mask restricts where components may be placed. A nil Mask allows every
+// pixel.
+type Mask [][]bool
+
+func (m Mask) allows(x, y int) bool {
+	return m == nil || m[x][y]
+}
+
+// Deconvolver is implemented by every CLEAN algorithm. Deconvolve removes the
+// PSF from dirty, placing components only inside mask, and returns the model,
+// the residual, the components that make up the model and why it stopped.
+// The PSF must be the same size as dirty with its peak at the centre pixel.
+type Deconvolver interface {
+	Deconvolve(dirty, psf Image, mask Mask, opts Options) (*CleanResult, error)
+}
+
+var (
+	registryMu   sync.RWMutex
+	deconvolvers = make(map[string]func() Deconvolver)
+)
+
+// Register makes a deconvolver available by name to NewDeconvolver and the
+// command-line tool. It panics if name is already registered.
+func Register(name string, factory func() Deconvolver) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := deconvolvers[name]; dup {
+		panic("clean: Register called twice for deconvolver " + name)
+	}
+	deconvolvers[name] = factory
+}
+
+// NewDeconvolver returns a new instance of the deconvolver registered as name.
+func NewDeconvolver(name string) (Deconvolver, error) {
+	registryMu.RLock()
+	factory, ok := deconvolvers[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown CLEAN algorithm %q", name)
+	}
+	return factory(), nil
+}
+
+// Deconvolvers returns the sorted names of the registered deconvolvers.
+func Deconvolvers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(deconvolvers))
+	for name := range deconvolvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register("multiscale", func() Deconvolver {
+		msc, _ := NewMultiScaleCleaner(Options{})
+		return msc
+	})
+	Register("hogbom", func() Deconvolver {
+		hc, _ := NewHogbomCleaner(Options{})
+		return hc
+	})
+	Register("clark", func() Deconvolver {
+		cc, _ := NewClarkCleaner(Options{})
+		return cc
+	})
+}
+
+// checkInputs checks that dirty, psf and mask have matching shapes and that
+// every starting model component lies inside the image.
+func checkInputs(dirty, psf Image, mask Mask, startModel []Component) error {
+	if len(dirty) == 0 || len(dirty[0]) != len(dirty) {
+		return fmt.Errorf("dirty image must be square and non-empty")
+	}
+	n := len(dirty)
+	if len(psf) != n || len(psf[0]) != n {
+		return fmt.Errorf("PSF is %dx%d, want %dx%d", len(psf), len(psf[0]), n, n)
+	}
+	if mask != nil && (len(mask) != n || len(mask[0]) != n) {
+		return fmt.Errorf("mask is %dx%d, want %dx%d", len(mask), len(mask[0]), n, n)
+	}
+	for i, c := range startModel {
+		if c.X < 0 || c.X >= n || c.Y < 0 || c.Y >= n {
+			return fmt.Errorf("starting model component %d at (%d, %d) lies outside the %dx%d image", i, c.X, c.Y, n, n)
+		}
+	}
+	return nil
+}
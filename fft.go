@@ -0,0 +1,202 @@
+package clean
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+)
+
+// fftPlan holds the bit-reversal permutation and twiddle factors of a
+// radix-2 complex FFT of length n.
+type fftPlan struct {
+	n       int
+	rev     []int
+	twiddle []complex128
+}
+
+var (
+	planMu sync.Mutex
+	plans  = make(map[int]*fftPlan)
+)
+
+// planFor returns the cached plan for length n, which must be a power of two.
+func planFor(n int) *fftPlan {
+	planMu.Lock()
+	defer planMu.Unlock()
+	if p, ok := plans[n]; ok {
+		return p
+	}
+
+	p := &fftPlan{
+		n:       n,
+		rev:     make([]int, n),
+		twiddle: make([]complex128, n/2),
+	}
+	shift := bits.UintSize - bits.TrailingZeros(uint(n))
+	for i := range p.rev {
+		if n > 1 {
+			p.rev[i] = int(bits.Reverse(uint(i)) >> shift)
+		}
+	}
+	for k := range p.twiddle {
+		sin, cos := math.Sincos(-2 * math.Pi * float64(k) / float64(n))
+		p.twiddle[k] = complex(cos, sin)
+	}
+	plans[n] = p
+	return p
+}
+
+// transform computes the unnormalised forward or inverse FFT of x in place.
+func (p *fftPlan) transform(x []complex128, inverse bool) {
+	for i, r := range p.rev {
+		if i < r {
+			x[i], x[r] = x[r], x[i]
+		}
+	}
+	for size := 2; size <= p.n; size <<= 1 {
+		half := size / 2
+		step := p.n / size
+		for start := 0; start < p.n; start += size {
+			for k := 0; k < half; k++ {
+				w := p.twiddle[k*step]
+				if inverse {
+					w = complex(real(w), -imag(w))
+				}
+				a := x[start+k]
+				b := x[start+k+half] * w
+				x[start+k] = a + b
+				x[start+k+half] = a - b
+			}
+		}
+	}
+}
+
+// spectrum is the half-plane 2D FFT of a real h×w grid: h rows of w/2+1
+// frequencies, stored row-major.
+type spectrum struct {
+	h, w int
+	data []complex128
+}
+
+// forwardReal returns the spectrum of img zero-padded to h×w. Pairs of rows
+// are packed into one complex FFT and separated using Hermitian symmetry.
+func forwardReal(img Image, h, w int) *spectrum {
+	wc := w/2 + 1
+	spec := &spectrum{h: h, w: w, data: make([]complex128, h*wc)}
+	rows, cols := planFor(w), planFor(h)
+
+	z := make([]complex128, w)
+	for r := 0; r < h; r += 2 {
+		for j := range z {
+			var a, b float64
+			if r < len(img) && j < len(img[r]) {
+				a = img[r][j]
+			}
+			if r+1 < len(img) && j < len(img[r+1]) {
+				b = img[r+1][j]
+			}
+			z[j] = complex(a, b)
+		}
+		rows.transform(z, false)
+		for k := 0; k < wc; k++ {
+			zk := z[k]
+			zc := z[(w-k)%w]
+			zc = complex(real(zc), -imag(zc))
+			spec.data[r*wc+k] = (zk + zc) / 2
+			spec.data[(r+1)*wc+k] = (zk - zc) / complex(0, 2)
+		}
+	}
+
+	col := make([]complex128, h)
+	for k := 0; k < wc; k++ {
+		for r := range col {
+			col[r] = spec.data[r*wc+k]
+		}
+		cols.transform(col, false)
+		for r := range col {
+			spec.data[r*wc+k] = col[r]
+		}
+	}
+	return spec
+}
+
+// inverseReal transforms spec back to the real grid in place and returns the
+// rows offX..offX+outH-1 and columns offY..offY+outW-1.
+func inverseReal(spec *spectrum, offX, offY, outH, outW int) Grid {
+	h, w := spec.h, spec.w
+	wc := w/2 + 1
+	rows, cols := planFor(w), planFor(h)
+
+	col := make([]complex128, h)
+	for k := 0; k < wc; k++ {
+		for r := range col {
+			col[r] = spec.data[r*wc+k]
+		}
+		cols.transform(col, true)
+		for r := range col {
+			spec.data[r*wc+k] = col[r]
+		}
+	}
+
+	out := NewGrid(outH, outW)
+	scale := 1 / float64(h*w)
+	z := make([]complex128, w)
+	for r := offX &^ 1; r < offX+outH; r += 2 {
+		for k := 0; k < w; k++ {
+			var a, b complex128
+			if k < wc {
+				a, b = spec.data[r*wc+k], spec.data[(r+1)*wc+k]
+			} else {
+				a, b = spec.data[r*wc+w-k], spec.data[(r+1)*wc+w-k]
+				a = complex(real(a), -imag(a))
+				b = complex(real(b), -imag(b))
+			}
+			z[k] = a + complex(0, 1)*b
+		}
+		rows.transform(z, true)
+		for i, row := range []int{r, r + 1} {
+			if row < offX || row >= offX+outH {
+				continue
+			}
+			dst := out.Row(row - offX)
+			for j := range dst {
+				v := z[offY+j]
+				if i == 0 {
+					dst[j] = real(v) * scale
+				} else {
+					dst[j] = imag(v) * scale
+				}
+			}
+		}
+	}
+	return out
+}
+
+// convolveGrid returns the full or same-size linear convolution of img with
+// kernel in a contiguous grid. In same mode the result has the size of img
+// and the kernel is centred on its middle pixel.
+func convolveGrid(img, kernel Image, same bool) Grid {
+	h1, w1 := len(img), len(img[0])
+	h2, w2 := len(kernel), len(kernel[0])
+	h := nextPowerOfTwo(h1 + h2 - 1)
+	w := nextPowerOfTwo(w1 + w2 - 1)
+
+	spec := forwardReal(img, h, w)
+	kspec := forwardReal(kernel, h, w)
+	for i, v := range kspec.data {
+		spec.data[i] *= v
+	}
+
+	if same {
+		return inverseReal(spec, h2/2, w2/2, h1, w1)
+	}
+	return inverseReal(spec, 0, 0, h1+h2-1, w1+w2-1)
+}
+
+func nextPowerOfTwo(n int) int {
+	p := 2
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
@@ -0,0 +1,94 @@
+package clean
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func randomImage(rng *rand.Rand, h, w int) Image {
+	img := newImage(h, w)
+	for i := range img {
+		for j := range img[i] {
+			img[i][j] = rng.NormFloat64()
+		}
+	}
+	return img
+}
+
+// naiveConvolve is a serial reference for the full linear convolution.
+func naiveConvolve(a, b Image) Image {
+	out := newImage(len(a)+len(b)-1, len(a[0])+len(b[0])-1)
+	for i := range a {
+		for j := range a[i] {
+			for k := range b {
+				for l := range b[k] {
+					out[i+k][j+l] += a[i][j] * b[k][l]
+				}
+			}
+		}
+	}
+	return out
+}
+
+func TestConvolveMatchesDirectSum(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, tc := range []struct{ h1, w1, h2, w2 int }{
+		{1, 1, 1, 1},
+		{8, 8, 3, 3},
+		{17, 9, 5, 12},
+		{32, 32, 32, 32},
+		{31, 31, 61, 61},
+	} {
+		a := randomImage(rng, tc.h1, tc.w1)
+		b := randomImage(rng, tc.h2, tc.w2)
+		want := naiveConvolve(a, b)
+
+		got := convolve(a, b)
+		if d := maxAbsDiff(got, want); d > 1e-9 {
+			t.Errorf("full %v: max difference %g", tc, d)
+		}
+
+		same := convolveSame(a, b)
+		for i := range same {
+			for j := range same[i] {
+				if d := math.Abs(same[i][j] - want[i+tc.h2/2][j+tc.w2/2]); d > 1e-9 {
+					t.Fatalf("same %v: pixel (%d, %d) differs by %g", tc, i, j, d)
+				}
+			}
+		}
+	}
+}
+
+func maxAbsDiff(a, b Image) float64 {
+	d := 0.0
+	for i := range a {
+		for j := range a[i] {
+			d = math.Max(d, math.Abs(a[i][j]-b[i][j]))
+		}
+	}
+	return d
+}
+
+func BenchmarkConvolve(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	pool := newWorkerPool(DefaultOptions())
+	for _, n := range benchSizes {
+		img := randomImage(rng, n, n)
+		kernel := randomImage(rng, n, n)
+		if n <= 128 {
+			// Direct summation is O(n⁴); larger sizes take minutes.
+			b.Run(fmt.Sprintf("direct/%d", n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					convolveDirect(pool, img, kernel)
+				}
+			})
+		}
+		b.Run(fmt.Sprintf("fft/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				convolve(img, kernel)
+			}
+		})
+	}
+}
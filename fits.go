@@ -0,0 +1,78 @@
+package clean
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	fitsBlockSize = 2880
+	fitsCardSize  = 80
+)
+
+// fitsHeader accumulates the 80-character cards of a FITS header.
+type fitsHeader struct {
+	cards []string
+}
+
+func (h *fitsHeader) card(keyword, value, comment string) {
+	c := fmt.Sprintf("%-8s= %20s", keyword, value)
+	if comment != "" {
+		c += " / " + comment
+	}
+	h.cards = append(h.cards, c)
+}
+
+func (h *fitsHeader) bool(keyword string, v bool, comment string) {
+	value := "F"
+	if v {
+		value = "T"
+	}
+	h.card(keyword, value, comment)
+}
+
+func (h *fitsHeader) int(keyword string, v int, comment string) {
+	h.card(keyword, fmt.Sprintf("%d", v), comment)
+}
+
+func (h *fitsHeader) float(keyword string, v float64, comment string) {
+	h.card(keyword, strings.ToUpper(fmt.Sprintf("%.15G", v)), comment)
+}
+
+func (h *fitsHeader) string(keyword, v, comment string) {
+	quoted := "'" + fmt.Sprintf("%-8s", strings.ReplaceAll(v, "'", "''")) + "'"
+	c := fmt.Sprintf("%-8s= %-20s", keyword, quoted)
+	if comment != "" {
+		c += " / " + comment
+	}
+	h.cards = append(h.cards, c)
+}
+
+// writeTo writes the header followed by the END card, padded to a whole
+// number of blocks.
+func (h *fitsHeader) writeTo(w io.Writer) error {
+	var buf bytes.Buffer
+	for _, c := range append(h.cards, "END") {
+		if len(c) > fitsCardSize {
+			c = c[:fitsCardSize]
+		}
+		fmt.Fprintf(&buf, "%-80s", c)
+	}
+	return writeFITSBlocks(w, buf.Bytes(), ' ')
+}
+
+// writeFITSBlocks writes data padded with pad to a multiple of the FITS block
+// size.
+func writeFITSBlocks(w io.Writer, data []byte, pad byte) error {
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if rem := len(data) % fitsBlockSize; rem != 0 {
+		if _, err := w.Write(bytes.Repeat([]byte{pad}, fitsBlockSize-rem)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,443 @@
+package clean
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mjdEpoch is modified Julian date 0.
+var mjdEpoch = time.Date(1858, time.November, 17, 0, 0, 0, 0, time.UTC)
+
+// FITSMetadata describes the observation behind an image written by
+// WriteFITSImage. Zero fields are left out of the header.
+type FITSMetadata struct {
+	Object    string
+	Telescope string
+	DateObs   time.Time
+	// Frequency is the reference frequency and Bandwidth the total
+	// bandwidth, both in Hz.
+	Frequency float64
+	Bandwidth float64
+	// BUnit is the brightness unit, such as JY/BEAM or JY/PIXEL.
+	BUnit string
+	// WCS places the image on the sky. It is required when writing, and
+	// zero when a file read has no celestial axes.
+	WCS WCS
+	// Beam is the restoring beam in pixels.
+	Beam Beam
+}
+
+// FITSMetadata returns the object, date and frequency of the observation.
+// The frequency is the mean of the band frequencies, and the bandwidth is
+// the channel bandwidth times the number of bands.
+func (d *ACBData) FITSMetadata() (FITSMetadata, error) {
+	meta := FITSMetadata{Object: d.Source}
+	if d.TimeRange != "" {
+		start, err := parseACBTime(d.TimeRange)
+		if err != nil {
+			return meta, err
+		}
+		meta.DateObs = start
+	}
+	freqs := getUniqueFrequencies(d.Frequencies)
+	for _, f := range freqs {
+		meta.Frequency += f * 1e9 / float64(len(freqs))
+	}
+	if d.Bandwidth != "" {
+		bw, err := parseACBBandwidth(d.Bandwidth)
+		if err != nil {
+			return meta, err
+		}
+		meta.Bandwidth = bw * float64(len(freqs))
+	}
+	return meta, nil
+}
+
+// parseACBTime returns the start of an ACB time range such as
+// "58232 15h06m00.00s 58232 15h06m30.00s", given as MJD and time of day.
+func parseACBTime(timeRange string) (time.Time, error) {
+	parts := strings.Fields(timeRange)
+	if len(parts) < 2 {
+		return time.Time{}, fmt.Errorf("bad time range %q", timeRange)
+	}
+	mjd, err := strconv.Atoi(parts[0])
+	if err != nil || mjd < 0 || mjd > 1e6 {
+		return time.Time{}, fmt.Errorf("bad MJD in time range %q", timeRange)
+	}
+	tod, err := time.ParseDuration(parts[1])
+	if err != nil || tod < 0 || tod >= 24*time.Hour {
+		return time.Time{}, fmt.Errorf("bad time of day in time range %q", timeRange)
+	}
+	return mjdEpoch.AddDate(0, 0, mjd).Add(tod), nil
+}
+
+// parseACBBandwidth converts a bandwidth such as "58.000 MHz" to Hz.
+func parseACBBandwidth(s string) (float64, error) {
+	parts := strings.Fields(s)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("bad bandwidth %q", s)
+	}
+	v, err := parseFinite(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("bad bandwidth %q", s)
+	}
+	scale := map[string]float64{"Hz": 1, "kHz": 1e3, "MHz": 1e6, "GHz": 1e9}[parts[1]]
+	if scale == 0 {
+		return 0, fmt.Errorf("unknown bandwidth unit in %q", s)
+	}
+	return v * scale, nil
+}
+
+// WriteFITSImage writes img as the primary HDU of a FITS file, in IEEE
+// single (bitpix -32) or double (bitpix -64) precision. The image has x along
+// the first axis, with east to the left, and y northwards along the second;
+// degenerate frequency and Stokes axes follow, as AIPS, Difmap and CASA
+// expect.
+func WriteFITSImage(w io.Writer, img Image, bitpix int, meta FITSMetadata) error {
+	if bitpix != -32 && bitpix != -64 {
+		return fmt.Errorf("BITPIX %d is not -32 or -64", bitpix)
+	}
+	if len(img) == 0 || len(img[0]) == 0 {
+		return fmt.Errorf("empty image")
+	}
+	width, height := len(img), len(img[0])
+	for _, row := range img {
+		if len(row) != height {
+			return fmt.Errorf("image rows have different lengths")
+		}
+	}
+	wcs := meta.WCS
+	if err := wcs.Validate(); err != nil {
+		return err
+	}
+
+	var h fitsHeader
+	h.bool("SIMPLE", true, "conforms to FITS standard")
+	h.int("BITPIX", bitpix, "IEEE floating point")
+	h.int("NAXIS", 4, "")
+	h.int("NAXIS1", width, "")
+	h.int("NAXIS2", height, "")
+	h.int("NAXIS3", 1, "")
+	h.int("NAXIS4", 1, "")
+	if meta.Object != "" {
+		h.string("OBJECT", meta.Object, "")
+	}
+	if meta.Telescope != "" {
+		h.string("TELESCOP", meta.Telescope, "")
+	}
+	if !meta.DateObs.IsZero() {
+		h.string("DATE-OBS", meta.DateObs.UTC().Format("2006-01-02T15:04:05.000"), "")
+	}
+	if meta.BUnit != "" {
+		h.string("BUNIT", meta.BUnit, "")
+	}
+	h.float("EQUINOX", 2000, "")
+	h.string("RADESYS", "FK5", "")
+	if meta.Beam.BMaj > 0 {
+		h.float("BMAJ", meta.Beam.BMaj*wcs.Cell.Degrees(), "beam major axis (deg)")
+		h.float("BMIN", meta.Beam.BMin*wcs.Cell.Degrees(), "beam minor axis (deg)")
+		h.float("BPA", meta.Beam.BPA, "beam position angle (deg)")
+	}
+
+	cdelt := wcs.Cell.Degrees()
+	axes := []struct {
+		ctype               string
+		crval, cdelt, crpix float64
+		cunit               string
+	}{
+		{"RA---" + wcs.Projection.String(), wcs.RA.Degrees(), -cdelt, wcs.RefX + 1, "deg"},
+		{"DEC--" + wcs.Projection.String(), wcs.Dec.Degrees(), cdelt, wcs.RefY + 1, "deg"},
+		{"FREQ", meta.Frequency, meta.Bandwidth, 1, "Hz"},
+		{"STOKES", 1, 1, 1, ""},
+	}
+	for i, ax := range axes {
+		n := strconv.Itoa(i + 1)
+		h.string("CTYPE"+n, ax.ctype, "")
+		h.float("CRVAL"+n, ax.crval, "")
+		h.float("CDELT"+n, ax.cdelt, "")
+		h.float("CRPIX"+n, ax.crpix, "")
+		if ax.cunit != "" {
+			h.string("CUNIT"+n, ax.cunit, "")
+		}
+	}
+	if meta.Frequency > 0 {
+		h.float("RESTFRQ", meta.Frequency, "rest frequency (Hz)")
+	}
+	h.string("ORIGIN", "clean", "")
+	if err := h.writeTo(w); err != nil {
+		return err
+	}
+
+	size := -bitpix / 8
+	data := make([]byte, width*height*size)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			b := data[(y*width+x)*size:]
+			if bitpix == -32 {
+				binary.BigEndian.PutUint32(b, math.Float32bits(float32(img[x][y])))
+			} else {
+				binary.BigEndian.PutUint64(b, math.Float64bits(img[x][y]))
+			}
+		}
+	}
+	return writeFITSBlocks(w, data, 0)
+}
+
+const (
+	// maxFITSHeaderBlocks bounds the header ReadFITSImage scans for END.
+	maxFITSHeaderBlocks = 1000
+	// maxFITSPixels bounds the pixels in one image plane, and the number of
+	// planes in a cube.
+	maxFITSPixels = 1 << 26
+)
+
+// ReadFITSImage reads a 2D image from the primary HDU of a FITS file, or one
+// plane of a 3D or 4D cube, numbering planes from 0 with the third axis
+// varying fastest. Integer pixels are scaled by BSCALE and BZERO, and blank or
+// NaN pixels are read as zero. The metadata comes from the header keywords
+// WriteFITSImage writes; the beam is converted to pixels when the cell size
+// is known.
+func ReadFITSImage(r io.Reader, plane int) (Image, FITSMetadata, error) {
+	var meta FITSMetadata
+	cards, err := readFITSHeader(r)
+	if err != nil {
+		return nil, meta, err
+	}
+	if cards["SIMPLE"] != "T" {
+		return nil, meta, fmt.Errorf("not a FITS file: SIMPLE is not T")
+	}
+	bitpix, err := strconv.Atoi(cards["BITPIX"])
+	if err != nil {
+		return nil, meta, fmt.Errorf("bad BITPIX %q", cards["BITPIX"])
+	}
+	size := map[int]int{8: 1, 16: 2, 32: 4, 64: 8, -32: 4, -64: 8}[bitpix]
+	if size == 0 {
+		return nil, meta, fmt.Errorf("unsupported BITPIX %d", bitpix)
+	}
+	naxis, err := strconv.Atoi(cards["NAXIS"])
+	if err != nil || naxis < 2 || naxis > 4 {
+		return nil, meta, fmt.Errorf("NAXIS %q: want a 2D image or a 3D or 4D cube", cards["NAXIS"])
+	}
+	axes := make([]int, naxis)
+	for i := range axes {
+		key := "NAXIS" + strconv.Itoa(i+1)
+		axes[i], err = strconv.Atoi(cards[key])
+		if err != nil || axes[i] < 1 || axes[i] > maxFITSPixels {
+			return nil, meta, fmt.Errorf("bad %s %q", key, cards[key])
+		}
+	}
+	width, height := axes[0], axes[1]
+	if width*height > maxFITSPixels {
+		return nil, meta, fmt.Errorf("%dx%d image exceeds %d pixels", width, height, maxFITSPixels)
+	}
+	planes := 1
+	for _, n := range axes[2:] {
+		planes *= n
+	}
+	if planes > maxFITSPixels {
+		return nil, meta, fmt.Errorf("cube has more than %d planes", maxFITSPixels)
+	}
+	if plane < 0 || plane >= planes {
+		return nil, meta, fmt.Errorf("plane %d outside the %d planes of the cube", plane, planes)
+	}
+
+	planeBytes := int64(width * height * size)
+	if _, err := io.CopyN(io.Discard, r, int64(plane)*planeBytes); err != nil {
+		return nil, meta, fmt.Errorf("reading FITS data: %v", err)
+	}
+	// Read through a LimitReader so the buffer grows with the data actually
+	// present rather than the size the header claims.
+	data, err := io.ReadAll(io.LimitReader(r, planeBytes))
+	if err != nil {
+		return nil, meta, fmt.Errorf("reading FITS data: %v", err)
+	}
+	if int64(len(data)) < planeBytes {
+		return nil, meta, fmt.Errorf("FITS data truncated: %d of %d bytes", len(data), planeBytes)
+	}
+
+	bscale, bzero := 1.0, 0.0
+	if v, ok := cards["BSCALE"]; ok {
+		if bscale, err = parseFinite(v); err != nil {
+			return nil, meta, fmt.Errorf("BSCALE: %v", err)
+		}
+	}
+	if v, ok := cards["BZERO"]; ok {
+		if bzero, err = parseFinite(v); err != nil {
+			return nil, meta, fmt.Errorf("BZERO: %v", err)
+		}
+	}
+	blank, hasBlank := int64(0), false
+	if v, ok := cards["BLANK"]; ok && bitpix > 0 {
+		blank, err = strconv.ParseInt(v, 10, 64)
+		hasBlank = err == nil
+	}
+
+	img := newImage(width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			b := data[(y*width+x)*size:]
+			var v float64
+			var raw int64
+			switch bitpix {
+			case 8:
+				raw = int64(b[0])
+			case 16:
+				raw = int64(int16(binary.BigEndian.Uint16(b)))
+			case 32:
+				raw = int64(int32(binary.BigEndian.Uint32(b)))
+			case 64:
+				raw = int64(binary.BigEndian.Uint64(b))
+			case -32:
+				v = float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+			case -64:
+				v = math.Float64frombits(binary.BigEndian.Uint64(b))
+			}
+			if bitpix > 0 {
+				if hasBlank && raw == blank {
+					continue
+				}
+				v = float64(raw)
+			}
+			v = bzero + bscale*v
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				img[x][y] = v
+			}
+		}
+	}
+
+	meta, err = fitsMetadata(cards, axes)
+	if err != nil {
+		return nil, meta, err
+	}
+	return img, meta, nil
+}
+
+// readFITSHeader reads header blocks up to the END card and returns the
+// keyword values, with strings unquoted and comments removed.
+func readFITSHeader(r io.Reader) (map[string]string, error) {
+	cards := make(map[string]string)
+	block := make([]byte, fitsBlockSize)
+	for n := 0; n < maxFITSHeaderBlocks; n++ {
+		if _, err := io.ReadFull(r, block); err != nil {
+			return nil, fmt.Errorf("reading FITS header: %v", err)
+		}
+		for i := 0; i < fitsBlockSize; i += fitsCardSize {
+			card := string(block[i : i+fitsCardSize])
+			keyword := strings.TrimSpace(card[:8])
+			if keyword == "END" {
+				return cards, nil
+			}
+			if card[8:10] != "= " {
+				continue
+			}
+			if _, ok := cards[keyword]; !ok {
+				cards[keyword] = fitsValue(card[10:])
+			}
+		}
+	}
+	return nil, fmt.Errorf("no END card in the first %d header blocks", maxFITSHeaderBlocks)
+}
+
+// fitsValue parses the value field of a card.
+func fitsValue(field string) string {
+	field = strings.TrimSpace(field)
+	if !strings.HasPrefix(field, "'") {
+		value, _, _ := strings.Cut(field, "/")
+		return strings.TrimSpace(value)
+	}
+	var sb strings.Builder
+	for i := 1; i < len(field); i++ {
+		if field[i] == '\'' {
+			if i+1 < len(field) && field[i+1] == '\'' {
+				sb.WriteByte('\'')
+				i++
+				continue
+			}
+			break
+		}
+		sb.WriteByte(field[i])
+	}
+	return strings.TrimRight(sb.String(), " ")
+}
+
+// fitsMetadata collects the metadata of an image with the given axis lengths.
+// Unparseable values are left zero. Celestial axes must be RA along x,
+// increasing to the east, and Dec along y, with square pixels in a SIN or TAN
+// projection.
+func fitsMetadata(cards map[string]string, axes []int) (FITSMetadata, error) {
+	number := func(key string) float64 {
+		v, err := parseFinite(cards[key])
+		if err != nil {
+			return 0
+		}
+		return v
+	}
+	meta := FITSMetadata{
+		Object:    cards["OBJECT"],
+		Telescope: cards["TELESCOP"],
+		BUnit:     cards["BUNIT"],
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, cards["DATE-OBS"]); err == nil {
+			meta.DateObs = t
+			break
+		}
+	}
+	for i := range axes {
+		n := strconv.Itoa(i + 1)
+		ctype := cards["CTYPE"+n]
+		switch {
+		case strings.HasPrefix(ctype, "RA--") || strings.HasPrefix(ctype, "DEC-"):
+			if err := readFITSWCSAxis(&meta.WCS, i, ctype, number("CRVAL"+n), number("CDELT"+n), number("CRPIX"+n)); err != nil {
+				return meta, err
+			}
+		case ctype == "FREQ":
+			meta.Frequency = number("CRVAL" + n)
+			meta.Bandwidth = math.Abs(number("CDELT"+n)) * float64(axes[i])
+		}
+	}
+	if meta.Frequency == 0 {
+		meta.Frequency = number("RESTFRQ")
+	}
+	if meta.WCS.Cell != 0 {
+		if err := meta.WCS.Validate(); err != nil {
+			return meta, err
+		}
+	}
+	if bmaj := number("BMAJ"); bmaj > 0 && meta.WCS.Cell > 0 {
+		meta.Beam = Beam{
+			BMaj: bmaj / meta.WCS.Cell.Degrees(),
+			BMin: number("BMIN") / meta.WCS.Cell.Degrees(),
+			BPA:  number("BPA"),
+		}
+	}
+	return meta, nil
+}
+
+// readFITSWCSAxis fills in wcs from the keywords of celestial axis i.
+func readFITSWCSAxis(wcs *WCS, i int, ctype string, crval, cdelt, crpix float64) error {
+	ra := strings.HasPrefix(ctype, "RA--")
+	if ra && (i != 0 || cdelt >= 0) || !ra && (i != 1 || cdelt <= 0) {
+		return fmt.Errorf("axis %d is %s with CDELT %g: want RA along axis 1 increasing to the east and Dec along axis 2", i+1, ctype, cdelt)
+	}
+	proj, err := ParseProjection(strings.TrimLeft(ctype[4:], "-"))
+	if err != nil {
+		return err
+	}
+	cell := Angle(math.Abs(cdelt)) * Degree
+	if wcs.Cell != 0 && (proj != wcs.Projection || math.Abs(float64(cell-wcs.Cell)) > 1e-6*float64(cell)) {
+		return fmt.Errorf("RA and Dec axes have different projections or pixel sizes")
+	}
+	wcs.Projection, wcs.Cell = proj, cell
+	if ra {
+		wcs.RA, wcs.RefX = Angle(crval)*Degree, crpix-1
+	} else {
+		wcs.Dec, wcs.RefY = Angle(crval)*Degree, crpix-1
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package clean
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fitsCard returns the value of keyword in a FITS header, or "" if absent.
+func fitsCard(header []byte, keyword string) string {
+	for i := 0; i+fitsCardSize <= len(header); i += fitsCardSize {
+		card := string(header[i : i+fitsCardSize])
+		if strings.TrimSpace(card[:8]) == keyword && card[8] == '=' {
+			value, _, _ := strings.Cut(card[10:], " /")
+			return strings.Trim(strings.TrimSpace(value), "' ")
+		}
+	}
+	return ""
+}
+
+func TestWriteFITSImage(t *testing.T) {
+	data, err := ParseACB(testACB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := data.FITSMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2018, time.April, 24, 15, 6, 0, 0, time.UTC); !meta.DateObs.Equal(want) {
+		t.Errorf("DateObs %v, want %v", meta.DateObs, want)
+	}
+	if math.Abs(meta.Frequency-213.071e9) > 1e6 || math.Abs(meta.Bandwidth-32*58e6) > 1 {
+		t.Errorf("frequency %g Hz, bandwidth %g Hz", meta.Frequency, meta.Bandwidth)
+	}
+
+	img := newImage(5, 3)
+	img[1][2] = 1.5
+	img[4][0] = -2
+	meta.WCS = NewWCS(5, 3, 0.5*Milliarcsecond)
+	meta.Beam = Beam{BMaj: 4, BMin: 2, BPA: 30}
+	meta.BUnit = "JY/BEAM"
+	for _, bitpix := range []int{-32, -64} {
+		var buf bytes.Buffer
+		if err := WriteFITSImage(&buf, img, bitpix, meta); err != nil {
+			t.Fatal(err)
+		}
+		out := buf.Bytes()
+		if len(out)%fitsBlockSize != 0 {
+			t.Fatalf("BITPIX %d: wrote %d bytes, not whole blocks", bitpix, len(out))
+		}
+		// The pixels fit in the last block.
+		header, pixels := out[:len(out)-fitsBlockSize], out[len(out)-fitsBlockSize:]
+		for keyword, want := range map[string]string{
+			"NAXIS1": "5", "NAXIS2": "3", "OBJECT": "BLLAC", "BUNIT": "JY/BEAM",
+			"DATE-OBS": "2018-04-24T15:06:00.000", "CRPIX1": "3", "CRPIX2": "2",
+			"CTYPE1": "RA---SIN", "BMAJ": "5.55555555555556E-07", "BPA": "30",
+			// ACB files do not name the telescope, so none is recorded.
+			"TELESCOP": "",
+		} {
+			if got := fitsCard(header, keyword); got != want {
+				t.Errorf("BITPIX %d: %s = %q, want %q", bitpix, keyword, got, want)
+			}
+		}
+
+		size := -bitpix / 8
+		for _, p := range []struct{ x, y int }{{1, 2}, {4, 0}, {0, 0}} {
+			b := pixels[(p.y*5+p.x)*size:]
+			var v float64
+			if bitpix == -32 {
+				v = float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+			} else {
+				v = math.Float64frombits(binary.BigEndian.Uint64(b))
+			}
+			if v != img[p.x][p.y] {
+				t.Errorf("BITPIX %d: pixel (%d, %d) = %g, want %g", bitpix, p.x, p.y, v, img[p.x][p.y])
+			}
+		}
+	}
+
+	if err := WriteFITSImage(&bytes.Buffer{}, img, 16, meta); err == nil {
+		t.Error("BITPIX 16 accepted")
+	}
+}
+
+func TestReadFITSImageRoundTrip(t *testing.T) {
+	img := newImage(6, 4)
+	for x := range img {
+		for y := range img[x] {
+			img[x][y] = float64(x*10+y) - 7.25
+		}
+	}
+	meta := FITSMetadata{
+		Object:    "3C 279",
+		Telescope: "EHT",
+		DateObs:   time.Date(2017, time.April, 11, 1, 2, 3, 0, time.UTC),
+		Frequency: 229e9,
+		Bandwidth: 4e9,
+		BUnit:     "JY/BEAM",
+		WCS: WCS{
+			Projection: TAN,
+			RefX:       2.5,
+			RefY:       1,
+			Cell:       2 * Microarcsecond,
+			RA:         194.05 * Degree,
+			Dec:        -5.79 * Degree,
+		},
+		Beam: Beam{BMaj: 10, BMin: 5, BPA: -20},
+	}
+	var buf bytes.Buffer
+	if err := WriteFITSImage(&buf, img, -64, meta); err != nil {
+		t.Fatal(err)
+	}
+	got, gotMeta, err := ReadFITSImage(&buf, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !identical(got, img) {
+		t.Error("pixels differ after a round trip")
+	}
+	if d := gotMeta.WCS.Cell - meta.WCS.Cell; math.Abs(float64(d)) > 1e-12*float64(meta.WCS.Cell) || math.Abs(gotMeta.Beam.BMaj-meta.Beam.BMaj) > 1e-9 {
+		t.Errorf("cell size %v, beam %v; want %v, %v", gotMeta.WCS.Cell, gotMeta.Beam, meta.WCS.Cell, meta.Beam)
+	}
+	for _, a := range []Angle{gotMeta.WCS.RA - meta.WCS.RA, gotMeta.WCS.Dec - meta.WCS.Dec} {
+		if math.Abs(float64(a)) > 1e-15 {
+			t.Errorf("phase centre %v, %v; want %v, %v", gotMeta.WCS.RA, gotMeta.WCS.Dec, meta.WCS.RA, meta.WCS.Dec)
+		}
+	}
+	gotMeta.WCS, gotMeta.Beam = meta.WCS, meta.Beam
+	if gotMeta != meta {
+		t.Errorf("metadata %+v, want %+v", gotMeta, meta)
+	}
+}
+
+func TestReadFITSImagePlane(t *testing.T) {
+	// A 3x2x2 cube of 16-bit integers with BSCALE, BZERO and a blank value.
+	var h fitsHeader
+	h.bool("SIMPLE", true, "")
+	h.int("BITPIX", 16, "")
+	h.int("NAXIS", 3, "")
+	h.int("NAXIS1", 3, "")
+	h.int("NAXIS2", 2, "")
+	h.int("NAXIS3", 2, "")
+	h.float("BSCALE", 0.5, "")
+	h.float("BZERO", 1, "")
+	h.int("BLANK", -32768, "")
+	var buf bytes.Buffer
+	if err := h.writeTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	pixels := []int16{0, 1, 2, 3, 4, 5, 10, 12, -32768, 16, 18, 20}
+	var data bytes.Buffer
+	binary.Write(&data, binary.BigEndian, pixels)
+	if err := writeFITSBlocks(&buf, data.Bytes(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	img, _, err := ReadFITSImage(bytes.NewReader(buf.Bytes()), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Image{{6, 9}, {7, 10}, {0, 11}}
+	if !identical(img, want) {
+		t.Errorf("plane 1 is %v, want %v", img, want)
+	}
+	if _, _, err := ReadFITSImage(bytes.NewReader(buf.Bytes()), 2); err == nil {
+		t.Error("plane 2 of a 2-plane cube accepted")
+	}
+}
@@ -0,0 +1,96 @@
+package clean
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"testing"
+)
+
+func FuzzReadACB(f *testing.F) {
+	seed, err := os.ReadFile(testACB)
+	if err != nil {
+		f.Fatal(err)
+	}
+	// The whole file is too large for the mutator to make progress, so seed
+	// with its header and first few bandfreq and amplitude records.
+	lines := bytes.SplitAfter(seed, []byte("\n"))
+	f.Add(bytes.Join(lines[:80], nil))
+	f.Add(lines[0])
+	f.Add([]byte("timerange: 58232\nbandfreq: 1e308 GHz polar: RR\n 1 LM 1 NaN\n"))
+	f.Add([]byte("timerange:\nsource:\nbandfreq:\n 1 LM\n"))
+
+	f.Fuzz(func(t *testing.T, in []byte) {
+		data, err := ReadACB(bytes.NewReader(in))
+		if err != nil {
+			return
+		}
+		if len(data.Frequencies) > maxACBRecords || len(data.Amplitudes) > maxACBRecords || len(data.Polarizations) > maxACBRecords {
+			t.Fatalf("kept %d frequencies, %d polarizations and %d amplitudes, limit %d",
+				len(data.Frequencies), len(data.Polarizations), len(data.Amplitudes), maxACBRecords)
+		}
+		for _, v := range append(data.Frequencies, data.Amplitudes...) {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				t.Fatalf("accepted non-finite value %v", v)
+			}
+		}
+	})
+}
+
+func FuzzReadDifmapModel(f *testing.F) {
+	f.Add([]byte("! Flux (Jy) Radius (mas) Theta (deg)\n1.5v 0.0v 0.0v\n0.25v 2.0v 90v 1.2v 1 0 1\n"), 64, 0.5)
+	f.Add([]byte("1 1e300 45\n"), 16, 1e-300)
+	f.Add([]byte("1 0 0 -1 -1 0 1\n"), 8, 1.0)
+
+	f.Fuzz(func(t *testing.T, in []byte, size int, cellSize float64) {
+		if size < 1 || size > 1<<12 {
+			return
+		}
+		wcs := NewWCS(size, size, Angle(cellSize)*Milliarcsecond)
+		comps, err := ReadDifmapModel(bytes.NewReader(in), size, wcs)
+		if err != nil {
+			return
+		}
+		for _, c := range comps {
+			if c.X < 0 || c.X >= size || c.Y < 0 || c.Y >= size {
+				t.Fatalf("component at (%d, %d) outside %d×%d image", c.X, c.Y, size, size)
+			}
+			if math.IsNaN(c.Flux) || math.IsInf(c.Flux, 0) {
+				t.Fatalf("accepted non-finite flux %v", c.Flux)
+			}
+			if math.IsNaN(c.Size) || math.IsInf(c.Size, 0) || c.Size < 0 {
+				t.Fatalf("accepted component size %v", c.Size)
+			}
+		}
+	})
+}
+
+func FuzzReadFITSImage(f *testing.F) {
+	img := newImage(4, 3)
+	img[1][2] = 1
+	for _, bitpix := range []int{-32, -64} {
+		var buf bytes.Buffer
+		meta := FITSMetadata{Object: "BLLAC", WCS: NewWCS(4, 3, 0.5*Milliarcsecond), Beam: Beam{BMaj: 2, BMin: 1}, Frequency: 213e9}
+		if err := WriteFITSImage(&buf, img, bitpix, meta); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf.Bytes(), 0)
+	}
+
+	f.Fuzz(func(t *testing.T, in []byte, plane int) {
+		img, _, err := ReadFITSImage(bytes.NewReader(in), plane)
+		if err != nil {
+			return
+		}
+		if len(img) == 0 || len(img)*len(img[0]) > maxFITSPixels {
+			t.Fatalf("read a %d-row image", len(img))
+		}
+		for _, row := range img {
+			for _, v := range row {
+				if math.IsNaN(v) || math.IsInf(v, 0) {
+					t.Fatalf("read non-finite pixel %v", v)
+				}
+			}
+		}
+	})
+}
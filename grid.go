@@ -0,0 +1,199 @@
+package clean
+
+import "fmt"
+
+// Float is the pixel type of a grid.
+type Float interface {
+	~float32 | ~float64
+}
+
+// GridOf is an image held in one contiguous slice. Pixel (x, y) is
+// Data[x*Stride+y], the same indexing as img[x][y] for an Image. Width is the
+// extent in x and Height the extent in y. A grid returned by View shares its
+// data with the parent and has a Stride larger than its Height.
+type GridOf[T Float] struct {
+	Data   []T
+	Width  int
+	Height int
+	Stride int
+}
+
+// Grid is a double-precision grid, the storage behind Image.
+type Grid = GridOf[float64]
+
+// Grid32 is a single-precision grid, used to halve the memory of large maps.
+type Grid32 = GridOf[float32]
+
+// NewGrid returns a zeroed width×height grid.
+func NewGrid(width, height int) Grid {
+	return newGrid[float64](width, height)
+}
+
+// NewGrid32 returns a zeroed width×height single-precision grid.
+func NewGrid32(width, height int) Grid32 {
+	return newGrid[float32](width, height)
+}
+
+func newGrid[T Float](width, height int) GridOf[T] {
+	return GridOf[T]{
+		Data:   make([]T, width*height),
+		Width:  width,
+		Height: height,
+		Stride: height,
+	}
+}
+
+// GridFromImage copies a rectangular Image into a new grid.
+func GridFromImage(img Image) Grid {
+	return gridFromImage[float64](img)
+}
+
+// Grid32FromImage copies a rectangular Image into a new single-precision
+// grid, rounding every pixel to float32.
+func Grid32FromImage(img Image) Grid32 {
+	return gridFromImage[float32](img)
+}
+
+func gridFromImage[T Float](img Image) GridOf[T] {
+	if len(img) == 0 {
+		return GridOf[T]{}
+	}
+	g := newGrid[T](len(img), len(img[0]))
+	for x, row := range img {
+		dst := g.Row(x)
+		for y, v := range row {
+			dst[y] = T(v)
+		}
+	}
+	return g
+}
+
+// convertGrid returns g in precision T. A grid already in that precision is
+// returned as is rather than copied.
+func convertGrid[T, U Float](g GridOf[U]) GridOf[T] {
+	if same, ok := any(g).(GridOf[T]); ok {
+		return same
+	}
+	c := newGrid[T](g.Width, g.Height)
+	for x := 0; x < g.Width; x++ {
+		dst, src := c.Row(x), g.Row(x)
+		for y, v := range src {
+			dst[y] = T(v)
+		}
+	}
+	return c
+}
+
+// Image returns g as an Image. For a float64 grid the rows alias the grid's
+// data, so writes through either are seen by both, and only the row headers
+// are allocated; other precisions are copied.
+func (g GridOf[T]) Image() Image {
+	f := convertGrid[float64](g)
+	img := make(Image, f.Width)
+	for x := range img {
+		img[x] = f.Row(x)
+	}
+	return img
+}
+
+// Row returns the pixels with the given x as a slice of the grid's data.
+func (g GridOf[T]) Row(x int) []T {
+	start := x * g.Stride
+	return g.Data[start : start+g.Height : start+g.Height]
+}
+
+// At returns pixel (x, y).
+func (g GridOf[T]) At(x, y int) T {
+	return g.Data[x*g.Stride+y]
+}
+
+// Set stores v at pixel (x, y).
+func (g GridOf[T]) Set(x, y int, v T) {
+	g.Data[x*g.Stride+y] = v
+}
+
+// View returns the width×height sub-image with its origin at (x, y). The
+// view shares data with g.
+func (g GridOf[T]) View(x, y, width, height int) GridOf[T] {
+	if x < 0 || y < 0 || width < 0 || height < 0 || x+width > g.Width || y+height > g.Height {
+		panic(fmt.Sprintf("clean: view %dx%d at (%d, %d) outside %dx%d grid", width, height, x, y, g.Width, g.Height))
+	}
+	if width == 0 || height == 0 {
+		return GridOf[T]{Width: width, Height: height, Stride: g.Stride}
+	}
+	start := x*g.Stride + y
+	end := start + (width-1)*g.Stride + height
+	return GridOf[T]{Data: g.Data[start:end:end], Width: width, Height: height, Stride: g.Stride}
+}
+
+// Clone returns a contiguous copy of g.
+func (g GridOf[T]) Clone() GridOf[T] {
+	c := newGrid[T](g.Width, g.Height)
+	c.CopyFrom(g)
+	return c
+}
+
+// CopyFrom overwrites g with src, which must have the same shape.
+func (g GridOf[T]) CopyFrom(src GridOf[T]) {
+	g.checkShape(src)
+	for x := 0; x < g.Width; x++ {
+		copy(g.Row(x), src.Row(x))
+	}
+}
+
+// Fill sets every pixel of g to v.
+func (g GridOf[T]) Fill(v T) {
+	for x := 0; x < g.Width; x++ {
+		row := g.Row(x)
+		for y := range row {
+			row[y] = v
+		}
+	}
+}
+
+// Scale multiplies every pixel of g by a.
+func (g GridOf[T]) Scale(a T) {
+	for x := 0; x < g.Width; x++ {
+		row := g.Row(x)
+		for y := range row {
+			row[y] *= a
+		}
+	}
+}
+
+// Add adds src, which must have the same shape, to g.
+func (g GridOf[T]) Add(src GridOf[T]) {
+	g.AddScaled(src, 1)
+}
+
+// AddScaled adds a*src, which must have the same shape, to g.
+func (g GridOf[T]) AddScaled(src GridOf[T], a T) {
+	g.checkShape(src)
+	for x := 0; x < g.Width; x++ {
+		dst, s := g.Row(x), src.Row(x)
+		for y := range dst {
+			dst[y] += a * s[y]
+		}
+	}
+}
+
+// AddShifted adds a*kernel to g with the kernel's centre pixel at (x, y),
+// dropping the parts of the kernel that fall outside g.
+func (g GridOf[T]) AddShifted(kernel GridOf[T], x, y int, a T) {
+	x0 := max(0, x-kernel.Width/2)
+	y0 := max(0, y-kernel.Height/2)
+	x1 := min(g.Width, x-kernel.Width/2+kernel.Width)
+	y1 := min(g.Height, y-kernel.Height/2+kernel.Height)
+	if x0 >= x1 || y0 >= y1 {
+		return
+	}
+	kx := x0 - (x - kernel.Width/2)
+	ky := y0 - (y - kernel.Height/2)
+	g.View(x0, y0, x1-x0, y1-y0).AddScaled(kernel.View(kx, ky, x1-x0, y1-y0), a)
+}
+
+func (g GridOf[T]) checkShape(o GridOf[T]) {
+	if g.Width != o.Width || g.Height != o.Height {
+		panic(fmt.Sprintf("clean: grid shapes %dx%d and %dx%d differ", g.Width, g.Height, o.Width, o.Height))
+	}
+}
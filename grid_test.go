@@ -0,0 +1,65 @@
+package clean
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestGridViewsShareData(t *testing.T) {
+	g := NewGrid(5, 4)
+	v := g.View(1, 2, 3, 2)
+	v.Fill(7)
+	img := g.Image()
+	for x := range img {
+		for y := range img[x] {
+			want := 0.0
+			if x >= 1 && x < 4 && y >= 2 {
+				want = 7
+			}
+			if img[x][y] != want {
+				t.Errorf("pixel (%d, %d) = %g, want %g", x, y, img[x][y], want)
+			}
+		}
+	}
+	img[2][3] = 1
+	if v.At(1, 1) != 1 {
+		t.Errorf("write through Image not seen by view")
+	}
+	if got := v.View(1, 1, 1, 1).At(0, 0); got != 1 {
+		t.Errorf("nested view read %g, want 1", got)
+	}
+}
+
+func TestGridRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	img := randomImage(rng, 6, 9)
+	g := GridFromImage(img)
+	if !identical(g.Image(), img) {
+		t.Fatal("GridFromImage(img).Image() differs from img")
+	}
+	c := g.View(1, 1, 4, 7).Clone()
+	if c.Stride != c.Height {
+		t.Errorf("clone stride %d, want %d", c.Stride, c.Height)
+	}
+	c.Scale(2)
+	c.AddScaled(g.View(1, 1, 4, 7), -2)
+	for _, v := range c.Data {
+		if v != 0 {
+			t.Fatalf("2*view - 2*view = %g", v)
+		}
+	}
+}
+
+func TestGridAddShiftedMatchesImage(t *testing.T) {
+	rng := rand.New(rand.NewSource(6))
+	kernel := randomImage(rng, 7, 5)
+	for _, pos := range []Point{{0, 0}, {3, 4}, {9, 9}, {-2, 3}, {12, 1}, {20, 20}} {
+		img := randomImage(rng, 10, 10)
+		g := GridFromImage(img)
+		g.AddShifted(GridFromImage(kernel), pos.x, pos.y, -0.5)
+		subtractShifted(img, kernel, pos, 0.5)
+		if !identical(g.Image(), img) {
+			t.Errorf("AddShifted at %v differs from subtractShifted", pos)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+// Package testutil holds helpers shared by the tests of clean and its
+// subpackages.
+package testutil
+
+import (
+	"os"
+	"testing"
+)
+
+// Quiet discards what is written to stdout for the rest of the test or
+// benchmark, as the deconvolvers report progress there.
+func Quiet(tb testing.TB) {
+	tb.Helper()
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = devNull
+	tb.Cleanup(func() {
+		os.Stdout = stdout
+		devNull.Close()
+	})
+}
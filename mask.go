@@ -0,0 +1,259 @@
+package clean
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// autoMaskGrowFraction is the fraction of the auto-mask seed level down to
+// which the mask is grown around each seed.
+const autoMaskGrowFraction = 0.5
+
+// NewMask returns a size×size mask with every pixel excluded.
+func NewMask(size int) Mask {
+	m := make(Mask, size)
+	for i := range m {
+		m[i] = make([]bool, size)
+	}
+	return m
+}
+
+// MaskFromImage returns a mask allowing every pixel of img above level.
+func MaskFromImage(img Image, level float64) Mask {
+	m := make(Mask, len(img))
+	for i := range img {
+		m[i] = make([]bool, len(img[i]))
+		for j, val := range img[i] {
+			m[i][j] = val > level
+		}
+	}
+	return m
+}
+
+// Count returns the number of allowed pixels.
+func (m Mask) Count() int {
+	n := 0
+	for _, row := range m {
+		for _, ok := range row {
+			if ok {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// sameMask reports whether a and b share their storage, so that a change to
+// either is seen by both.
+func sameMask(a, b Mask) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+func (m Mask) setWhere(inside func(x, y float64) bool, allow bool) {
+	for i := range m {
+		for j := range m[i] {
+			if inside(float64(i), float64(j)) {
+				m[i][j] = allow
+			}
+		}
+	}
+}
+
+// SetBox sets every pixel with x0 <= x <= x1 and y0 <= y <= y1.
+func (m Mask) SetBox(x0, y0, x1, y1 int, allow bool) {
+	for x := max(x0, 0); x <= x1 && x < len(m); x++ {
+		for y := max(y0, 0); y <= y1 && y < len(m[x]); y++ {
+			m[x][y] = allow
+		}
+	}
+}
+
+// SetCircle sets every pixel whose centre lies within r of (cx, cy).
+func (m Mask) SetCircle(cx, cy, r float64, allow bool) {
+	m.SetEllipse(cx, cy, r, r, 0, allow)
+}
+
+// SetEllipse sets every pixel whose centre lies inside the ellipse centred on
+// (cx, cy) with semi-axes a along x and b along y, rotated anticlockwise by
+// angle degrees.
+func (m Mask) SetEllipse(cx, cy, a, b, angle float64, allow bool) {
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	m.setWhere(func(x, y float64) bool {
+		dx, dy := x-cx, y-cy
+		u := dx*cos + dy*sin
+		v := -dx*sin + dy*cos
+		return (u*u)/(a*a)+(v*v)/(b*b) <= 1
+	}, allow)
+}
+
+// SetRotatedBox sets every pixel whose centre lies inside the w×h box centred
+// on (cx, cy), rotated anticlockwise by angle degrees.
+func (m Mask) SetRotatedBox(cx, cy, w, h, angle float64, allow bool) {
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	m.setWhere(func(x, y float64) bool {
+		dx, dy := x-cx, y-cy
+		u := dx*cos + dy*sin
+		v := -dx*sin + dy*cos
+		return math.Abs(u) <= w/2 && math.Abs(v) <= h/2
+	}, allow)
+}
+
+// SetPolygon sets every pixel whose centre lies inside the polygon with the
+// given vertices, using the even-odd rule.
+func (m Mask) SetPolygon(xs, ys []float64, allow bool) {
+	m.setWhere(func(x, y float64) bool {
+		inside := false
+		for i, j := 0, len(xs)-1; i < len(xs); j, i = i, i+1 {
+			if (ys[i] > y) != (ys[j] > y) && x < (xs[j]-xs[i])*(y-ys[i])/(ys[j]-ys[i])+xs[i] {
+				inside = !inside
+			}
+		}
+		return inside
+	}, allow)
+}
+
+// LoadDS9Regions builds a size×size mask from a DS9 region file in image
+// coordinates. Circle, ellipse, box and polygon regions are supported; a
+// leading '-' excludes the region from the mask.
+func LoadDS9Regions(filename string, size int) (Mask, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open region file: %v", err)
+	}
+	defer file.Close()
+
+	m := NewMask(size)
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		for _, entry := range strings.Split(line, ";") {
+			if err := m.applyDS9Region(strings.TrimSpace(entry)); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", filename, lineNum, err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning region file: %v", err)
+	}
+
+	return m, nil
+}
+
+func (m Mask) applyDS9Region(entry string) error {
+	switch {
+	case entry == "" || strings.HasPrefix(entry, "global") || entry == "image" || entry == "physical":
+		return nil
+	case entry == "fk4" || entry == "fk5" || entry == "icrs" || entry == "galactic" || entry == "ecliptic" || entry == "wcs":
+		return fmt.Errorf("only image coordinates are supported, got %s", entry)
+	}
+
+	allow := true
+	if entry[0] == '-' || entry[0] == '+' {
+		allow = entry[0] == '+'
+		entry = entry[1:]
+	}
+	open := strings.Index(entry, "(")
+	end := strings.LastIndex(entry, ")")
+	if open < 0 || end < open {
+		return fmt.Errorf("malformed region %q", entry)
+	}
+	shape := strings.TrimSpace(entry[:open])
+	var args []float64
+	for _, field := range strings.Split(entry[open+1:end], ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return fmt.Errorf("bad %s argument %q", shape, field)
+		}
+		args = append(args, v)
+	}
+
+	// DS9 image coordinates are 1-based.
+	switch {
+	case shape == "circle" && len(args) == 3:
+		m.SetCircle(args[0]-1, args[1]-1, args[2], allow)
+	case shape == "ellipse" && (len(args) == 4 || len(args) == 5):
+		m.SetEllipse(args[0]-1, args[1]-1, args[2], args[3], optionalAngle(args, 4), allow)
+	case shape == "box" && (len(args) == 4 || len(args) == 5):
+		m.SetRotatedBox(args[0]-1, args[1]-1, args[2], args[3], optionalAngle(args, 4), allow)
+	case shape == "polygon" && len(args) >= 6 && len(args)%2 == 0:
+		xs := make([]float64, len(args)/2)
+		ys := make([]float64, len(args)/2)
+		for i := range xs {
+			xs[i] = args[2*i] - 1
+			ys[i] = args[2*i+1] - 1
+		}
+		m.SetPolygon(xs, ys, allow)
+	default:
+		return fmt.Errorf("unsupported region %s with %d arguments", shape, len(args))
+	}
+	return nil
+}
+
+func optionalAngle(args []float64, i int) float64 {
+	if i < len(args) {
+		return args[i]
+	}
+	return 0
+}
+
+// activeMask returns the mask to clean within: the user mask, or the
+// auto-mask current grown from residual when auto-masking is enabled.
+func activeMask[T Float](opts Options, current, user Mask, residual GridOf[T], noise float64) Mask {
+	if opts.AutoMaskSigma <= 0 {
+		return user
+	}
+	return growAutoMask(current, user, residual, noise, opts)
+}
+
+// growAutoMask adds to auto the residual peaks above opts.AutoMaskSigma times
+// noise, grown to the connected pixels above half that level, and returns
+// it. Pixels outside the user mask are never added. A nil auto starts an
+// empty mask.
+func growAutoMask[T Float](auto, user Mask, residual GridOf[T], noise float64, opts Options) Mask {
+	if auto == nil {
+		auto = NewMask(residual.Width)
+	}
+	level := opts.AutoMaskSigma * noise
+	key := func(val T) float64 {
+		if opts.AbsolutePeak {
+			return math.Abs(float64(val))
+		}
+		return float64(val)
+	}
+
+	var stack []Point
+	for i := 0; i < residual.Width; i++ {
+		for j, val := range residual.Row(i) {
+			if key(val) > level && user.allows(i, j) && !auto[i][j] {
+				stack = append(stack, Point{x: i, y: j})
+			}
+		}
+	}
+	added := 0
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if p.x < 0 || p.x >= residual.Width || p.y < 0 || p.y >= residual.Height {
+			continue
+		}
+		if auto[p.x][p.y] || !user.allows(p.x, p.y) || key(residual.At(p.x, p.y)) <= autoMaskGrowFraction*level {
+			continue
+		}
+		auto[p.x][p.y] = true
+		added++
+		stack = append(stack, Point{p.x + 1, p.y}, Point{p.x - 1, p.y}, Point{p.x, p.y + 1}, Point{p.x, p.y - 1})
+	}
+	if added > 0 {
+		fmt.Printf("  Auto-mask grew by %d pixels to %d\n", added, auto.Count())
+	}
+	return auto
+}
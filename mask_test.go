@@ -0,0 +1,158 @@
+package clean
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func loadRegions(t *testing.T, regions string, size int) (Mask, error) {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "mask.reg")
+	if err := os.WriteFile(filename, []byte(regions), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return LoadDS9Regions(filename, size)
+}
+
+func TestLoadDS9Regions(t *testing.T) {
+	m, err := loadRegions(t, `# Region file format: DS9 version 4.1
+global color=green
+image
+circle(11,11,3)
+box(21,6,5,3) # a comment
+polygon(3,21,9,21,3,27); -circle(11,11,1)
+`, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		x, y  int
+		allow bool
+	}{
+		// The circle is centred on (10, 10) with radius 3, less the
+		// excluded unit circle at its centre.
+		{10, 13, true}, {13, 10, true}, {12, 12, true}, {10, 14, false}, {13, 13, false},
+		{10, 10, false}, {10, 11, false}, {10, 12, true},
+		// The box spans x 17.5–22.5 and y 3.5–6.5.
+		{18, 4, true}, {22, 6, true}, {17, 5, false}, {20, 7, false}, {20, 3, false},
+		// The triangle has vertices (2, 20), (8, 20) and (2, 26).
+		{3, 21, true}, {4, 23, true}, {7, 25, false}, {1, 22, false},
+	} {
+		if got := m[tc.x][tc.y]; got != tc.allow {
+			t.Errorf("pixel (%d, %d) allowed %v, want %v", tc.x, tc.y, got, tc.allow)
+		}
+	}
+}
+
+func TestLoadDS9RegionsRejectsMalformedLines(t *testing.T) {
+	for _, line := range []string{
+		"circle(1,2)",
+		"circle 1,2,3",
+		"box(1,2,x,4)",
+		"ellipse(1,2,3)",
+		"polygon(1,1,2,2)",
+		"point(1,1)",
+		"fk5",
+	} {
+		_, err := loadRegions(t, "image\n"+line+"\n", 16)
+		if err == nil {
+			t.Errorf("%q: no error", line)
+		} else if !strings.Contains(err.Error(), ":2:") {
+			t.Errorf("%q: error %q does not name line 2", line, err)
+		}
+	}
+}
+
+func TestAutoMaskGrowsFromPeaks(t *testing.T) {
+	n := 32
+	blob := func(cx, cy, peak float64) func(x, y int) float64 {
+		return func(x, y int) float64 {
+			dx, dy := float64(x)-cx, float64(y)-cy
+			return peak * math.Exp(-(dx*dx+dy*dy)/(2*2*2))
+		}
+	}
+	bright, faint := blob(8, 8, 10), blob(24, 24, 4)
+	residual := newImage(n, n)
+	for x := range residual {
+		for y := range residual[x] {
+			residual[x][y] = bright(x, y) + faint(x, y)
+		}
+	}
+	opts := Options{AutoMaskSigma: 5}
+
+	m := activeMask(opts, nil, nil, GridFromImage(residual), 1)
+	for x := range m {
+		for y := range m[x] {
+			// Pixels connected to the bright seed above half the seed level
+			// are added; the faint source is never seeded.
+			want := x < 16 && bright(x, y) > 2.5
+			if m[x][y] != want {
+				t.Fatalf("pixel (%d, %d) = %.2f allowed %v, want %v", x, y, residual[x][y], m[x][y], want)
+			}
+		}
+	}
+
+	// Later passes keep the mask and never leave the user mask.
+	user := NewMask(n)
+	user.SetBox(0, 0, n-1, 25, true)
+	before := m.Count()
+	residual[24][20] = 20
+	residual[24][28] = 20
+	m = activeMask(opts, m, user, GridFromImage(residual), 1)
+	if m.Count() != before+1 || !m[8][8] || !m[24][20] || m[24][28] {
+		t.Errorf("auto-mask grew from %d to %d pixels; bright source %v, new peak %v, peak outside user mask %v",
+			before, m.Count(), m[8][8], m[24][20], m[24][28])
+	}
+}
+
+func TestUnchangedMaskIsNotRescanned(t *testing.T) {
+	dirty, psf := pointSky(32, map[Point]float64{{12, 14}: 1})
+	searches := 0
+	msc, err := NewMultiScaleCleaner(Options{
+		ScaleSizes: []float64{0, 2},
+		TaskTimer: func(tt TaskTiming) {
+			if tt.Stage == "find peaks" {
+				searches++
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msc.imageSize = len(dirty)
+	msc.setPSF(psf)
+	beams, err := multiScaleBeams[float64](msc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := newMSWorkspace(msc, beams, GridFromImage(dirty))
+
+	// Each search runs one task per scale.
+	auto := NewMask(len(dirty))
+	auto.SetBox(10, 10, 14, 16, true)
+	other := NewMask(len(dirty))
+	other.SetBox(10, 10, 14, 16, true)
+	other[20][20] = true
+	for _, step := range []struct {
+		what string
+		mask Mask
+		grow bool
+		want int
+	}{
+		{"first mask", auto, false, 2},
+		{"same mask", auto, false, 2},
+		{"mask grown in place", auto, true, 4},
+		{"equal mask in other storage", other, false, 6},
+	} {
+		if step.grow {
+			auto[20][20] = true
+		}
+		ws.setMask(step.mask)
+		if searches != step.want {
+			t.Errorf("%s: %d search tasks in all, want %d", step.what, searches, step.want)
+		}
+	}
+}
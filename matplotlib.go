@@ -0,0 +1,1074 @@
+package clean
+
+import "image/color"
+
+// Magma is a perceptually uniform sequential colormap from black through
+// purple and orange to pale yellow. Like Viridis it was developed by
+// Stéfan van der Walt, Nathaniel Smith and Eric Firing for Matplotlib and is
+// available under a CC0 license.
+var Magma Continuous
+
+// Inferno is a perceptually uniform sequential colormap from black through
+// purple and red to bright yellow, from Matplotlib under a CC0 license.
+var Inferno Continuous
+
+// Plasma is a perceptually uniform sequential colormap from dark blue
+// through magenta to yellow, from Matplotlib under a CC0 license.
+var Plasma Continuous
+
+// Cividis is a sequential colormap from dark blue to yellow designed by
+// Jamie Nuñez, Christopher Anderton and Ryan Renslow to look nearly the same
+// to viewers with red-green colour vision deficiency. The stops are
+// Matplotlib's 256-entry cividis table rounded to 8 bits.
+var Cividis Continuous
+
+func init() {
+	Magma = RGBGradient{
+		Colors: []color.RGBA{
+			{0, 0, 4, 255},
+			{1, 0, 5, 255},
+			{1, 1, 6, 255},
+			{1, 1, 8, 255},
+			{2, 1, 9, 255},
+			{2, 2, 11, 255},
+			{2, 2, 13, 255},
+			{3, 3, 15, 255},
+			{3, 3, 18, 255},
+			{4, 4, 20, 255},
+			{5, 4, 22, 255},
+			{6, 5, 24, 255},
+			{6, 5, 26, 255},
+			{7, 6, 28, 255},
+			{8, 7, 30, 255},
+			{9, 7, 32, 255},
+			{10, 8, 34, 255},
+			{11, 9, 36, 255},
+			{12, 9, 38, 255},
+			{13, 10, 41, 255},
+			{14, 11, 43, 255},
+			{16, 11, 45, 255},
+			{17, 12, 47, 255},
+			{18, 13, 49, 255},
+			{19, 13, 52, 255},
+			{20, 14, 54, 255},
+			{21, 14, 56, 255},
+			{22, 15, 59, 255},
+			{24, 15, 61, 255},
+			{25, 16, 63, 255},
+			{26, 16, 66, 255},
+			{28, 16, 68, 255},
+			{29, 17, 71, 255},
+			{30, 17, 73, 255},
+			{32, 17, 75, 255},
+			{33, 17, 78, 255},
+			{34, 17, 80, 255},
+			{36, 18, 83, 255},
+			{37, 18, 85, 255},
+			{39, 18, 88, 255},
+			{41, 17, 90, 255},
+			{42, 17, 92, 255},
+			{44, 17, 95, 255},
+			{45, 17, 97, 255},
+			{47, 17, 99, 255},
+			{49, 17, 101, 255},
+			{51, 16, 103, 255},
+			{52, 16, 105, 255},
+			{54, 16, 107, 255},
+			{56, 16, 108, 255},
+			{57, 15, 110, 255},
+			{59, 15, 112, 255},
+			{61, 15, 113, 255},
+			{63, 15, 114, 255},
+			{64, 15, 116, 255},
+			{66, 15, 117, 255},
+			{68, 15, 118, 255},
+			{69, 16, 119, 255},
+			{71, 16, 120, 255},
+			{73, 16, 120, 255},
+			{74, 16, 121, 255},
+			{76, 17, 122, 255},
+			{78, 17, 123, 255},
+			{79, 18, 123, 255},
+			{81, 18, 124, 255},
+			{82, 19, 124, 255},
+			{84, 19, 125, 255},
+			{86, 20, 125, 255},
+			{87, 21, 126, 255},
+			{89, 21, 126, 255},
+			{90, 22, 126, 255},
+			{92, 22, 127, 255},
+			{93, 23, 127, 255},
+			{95, 24, 127, 255},
+			{96, 24, 128, 255},
+			{98, 25, 128, 255},
+			{100, 26, 128, 255},
+			{101, 26, 128, 255},
+			{103, 27, 128, 255},
+			{104, 28, 129, 255},
+			{106, 28, 129, 255},
+			{107, 29, 129, 255},
+			{109, 29, 129, 255},
+			{110, 30, 129, 255},
+			{112, 31, 129, 255},
+			{114, 31, 129, 255},
+			{115, 32, 129, 255},
+			{117, 33, 129, 255},
+			{118, 33, 129, 255},
+			{120, 34, 129, 255},
+			{121, 34, 130, 255},
+			{123, 35, 130, 255},
+			{124, 35, 130, 255},
+			{126, 36, 130, 255},
+			{128, 37, 130, 255},
+			{129, 37, 129, 255},
+			{131, 38, 129, 255},
+			{132, 38, 129, 255},
+			{134, 39, 129, 255},
+			{136, 39, 129, 255},
+			{137, 40, 129, 255},
+			{139, 41, 129, 255},
+			{140, 41, 129, 255},
+			{142, 42, 129, 255},
+			{144, 42, 129, 255},
+			{145, 43, 129, 255},
+			{147, 43, 128, 255},
+			{148, 44, 128, 255},
+			{150, 44, 128, 255},
+			{152, 45, 128, 255},
+			{153, 45, 128, 255},
+			{155, 46, 127, 255},
+			{156, 46, 127, 255},
+			{158, 47, 127, 255},
+			{160, 47, 127, 255},
+			{161, 48, 126, 255},
+			{163, 48, 126, 255},
+			{165, 49, 126, 255},
+			{166, 49, 125, 255},
+			{168, 50, 125, 255},
+			{170, 51, 125, 255},
+			{171, 51, 124, 255},
+			{173, 52, 124, 255},
+			{174, 52, 123, 255},
+			{176, 53, 123, 255},
+			{178, 53, 123, 255},
+			{179, 54, 122, 255},
+			{181, 54, 122, 255},
+			{183, 55, 121, 255},
+			{184, 55, 121, 255},
+			{186, 56, 120, 255},
+			{188, 57, 120, 255},
+			{189, 57, 119, 255},
+			{191, 58, 119, 255},
+			{192, 58, 118, 255},
+			{194, 59, 117, 255},
+			{196, 60, 117, 255},
+			{197, 60, 116, 255},
+			{199, 61, 115, 255},
+			{200, 62, 115, 255},
+			{202, 62, 114, 255},
+			{204, 63, 113, 255},
+			{205, 64, 113, 255},
+			{207, 64, 112, 255},
+			{208, 65, 111, 255},
+			{210, 66, 111, 255},
+			{211, 67, 110, 255},
+			{213, 68, 109, 255},
+			{214, 69, 108, 255},
+			{216, 69, 108, 255},
+			{217, 70, 107, 255},
+			{219, 71, 106, 255},
+			{220, 72, 105, 255},
+			{222, 73, 104, 255},
+			{223, 74, 104, 255},
+			{224, 76, 103, 255},
+			{226, 77, 102, 255},
+			{227, 78, 101, 255},
+			{228, 79, 100, 255},
+			{229, 80, 100, 255},
+			{231, 82, 99, 255},
+			{232, 83, 98, 255},
+			{233, 84, 98, 255},
+			{234, 86, 97, 255},
+			{235, 87, 96, 255},
+			{236, 88, 96, 255},
+			{237, 90, 95, 255},
+			{238, 91, 94, 255},
+			{239, 93, 94, 255},
+			{240, 95, 94, 255},
+			{241, 96, 93, 255},
+			{242, 98, 93, 255},
+			{242, 100, 92, 255},
+			{243, 101, 92, 255},
+			{244, 103, 92, 255},
+			{244, 105, 92, 255},
+			{245, 107, 92, 255},
+			{246, 108, 92, 255},
+			{246, 110, 92, 255},
+			{247, 112, 92, 255},
+			{247, 114, 92, 255},
+			{248, 116, 92, 255},
+			{248, 118, 92, 255},
+			{249, 120, 93, 255},
+			{249, 121, 93, 255},
+			{249, 123, 93, 255},
+			{250, 125, 94, 255},
+			{250, 127, 94, 255},
+			{250, 129, 95, 255},
+			{251, 131, 95, 255},
+			{251, 133, 96, 255},
+			{251, 135, 97, 255},
+			{252, 137, 97, 255},
+			{252, 138, 98, 255},
+			{252, 140, 99, 255},
+			{252, 142, 100, 255},
+			{252, 144, 101, 255},
+			{253, 146, 102, 255},
+			{253, 148, 103, 255},
+			{253, 150, 104, 255},
+			{253, 152, 105, 255},
+			{253, 154, 106, 255},
+			{253, 155, 107, 255},
+			{254, 157, 108, 255},
+			{254, 159, 109, 255},
+			{254, 161, 110, 255},
+			{254, 163, 111, 255},
+			{254, 165, 113, 255},
+			{254, 167, 114, 255},
+			{254, 169, 115, 255},
+			{254, 170, 116, 255},
+			{254, 172, 118, 255},
+			{254, 174, 119, 255},
+			{254, 176, 120, 255},
+			{254, 178, 122, 255},
+			{254, 180, 123, 255},
+			{254, 182, 124, 255},
+			{254, 183, 126, 255},
+			{254, 185, 127, 255},
+			{254, 187, 129, 255},
+			{254, 189, 130, 255},
+			{254, 191, 132, 255},
+			{254, 193, 133, 255},
+			{254, 194, 135, 255},
+			{254, 196, 136, 255},
+			{254, 198, 138, 255},
+			{254, 200, 140, 255},
+			{254, 202, 141, 255},
+			{254, 204, 143, 255},
+			{254, 205, 144, 255},
+			{254, 207, 146, 255},
+			{254, 209, 148, 255},
+			{254, 211, 149, 255},
+			{254, 213, 151, 255},
+			{254, 215, 153, 255},
+			{254, 216, 154, 255},
+			{253, 218, 156, 255},
+			{253, 220, 158, 255},
+			{253, 222, 160, 255},
+			{253, 224, 161, 255},
+			{253, 226, 163, 255},
+			{253, 227, 165, 255},
+			{253, 229, 167, 255},
+			{253, 231, 169, 255},
+			{253, 233, 170, 255},
+			{253, 235, 172, 255},
+			{252, 236, 174, 255},
+			{252, 238, 176, 255},
+			{252, 240, 178, 255},
+			{252, 242, 180, 255},
+			{252, 244, 182, 255},
+			{252, 246, 184, 255},
+			{252, 247, 185, 255},
+			{252, 249, 187, 255},
+			{252, 251, 189, 255},
+			{252, 253, 191, 255},
+		},
+	}
+
+	Inferno = RGBGradient{
+		Colors: []color.RGBA{
+			{0, 0, 4, 255},
+			{1, 0, 5, 255},
+			{1, 1, 6, 255},
+			{1, 1, 8, 255},
+			{2, 1, 10, 255},
+			{2, 2, 12, 255},
+			{2, 2, 14, 255},
+			{3, 2, 16, 255},
+			{4, 3, 18, 255},
+			{4, 3, 20, 255},
+			{5, 4, 23, 255},
+			{6, 4, 25, 255},
+			{7, 5, 27, 255},
+			{8, 5, 29, 255},
+			{9, 6, 31, 255},
+			{10, 7, 34, 255},
+			{11, 7, 36, 255},
+			{12, 8, 38, 255},
+			{13, 8, 41, 255},
+			{14, 9, 43, 255},
+			{16, 9, 45, 255},
+			{17, 10, 48, 255},
+			{18, 10, 50, 255},
+			{20, 11, 52, 255},
+			{21, 11, 55, 255},
+			{22, 11, 57, 255},
+			{24, 12, 60, 255},
+			{25, 12, 62, 255},
+			{27, 12, 65, 255},
+			{28, 12, 67, 255},
+			{30, 12, 69, 255},
+			{31, 12, 72, 255},
+			{33, 12, 74, 255},
+			{35, 12, 76, 255},
+			{36, 12, 79, 255},
+			{38, 12, 81, 255},
+			{40, 11, 83, 255},
+			{41, 11, 85, 255},
+			{43, 11, 87, 255},
+			{45, 11, 89, 255},
+			{47, 10, 91, 255},
+			{49, 10, 92, 255},
+			{50, 10, 94, 255},
+			{52, 10, 95, 255},
+			{54, 9, 97, 255},
+			{56, 9, 98, 255},
+			{57, 9, 99, 255},
+			{59, 9, 100, 255},
+			{61, 9, 101, 255},
+			{62, 9, 102, 255},
+			{64, 10, 103, 255},
+			{66, 10, 104, 255},
+			{68, 10, 104, 255},
+			{69, 10, 105, 255},
+			{71, 11, 106, 255},
+			{73, 11, 106, 255},
+			{74, 12, 107, 255},
+			{76, 12, 107, 255},
+			{77, 13, 108, 255},
+			{79, 13, 108, 255},
+			{81, 14, 108, 255},
+			{82, 14, 109, 255},
+			{84, 15, 109, 255},
+			{85, 15, 109, 255},
+			{87, 16, 110, 255},
+			{89, 16, 110, 255},
+			{90, 17, 110, 255},
+			{92, 18, 110, 255},
+			{93, 18, 110, 255},
+			{95, 19, 110, 255},
+			{97, 19, 110, 255},
+			{98, 20, 110, 255},
+			{100, 21, 110, 255},
+			{101, 21, 110, 255},
+			{103, 22, 110, 255},
+			{105, 22, 110, 255},
+			{106, 23, 110, 255},
+			{108, 24, 110, 255},
+			{109, 24, 110, 255},
+			{111, 25, 110, 255},
+			{113, 25, 110, 255},
+			{114, 26, 110, 255},
+			{116, 26, 110, 255},
+			{117, 27, 110, 255},
+			{119, 28, 109, 255},
+			{120, 28, 109, 255},
+			{122, 29, 109, 255},
+			{124, 29, 109, 255},
+			{125, 30, 109, 255},
+			{127, 30, 108, 255},
+			{128, 31, 108, 255},
+			{130, 32, 108, 255},
+			{132, 32, 107, 255},
+			{133, 33, 107, 255},
+			{135, 33, 107, 255},
+			{136, 34, 106, 255},
+			{138, 34, 106, 255},
+			{140, 35, 105, 255},
+			{141, 35, 105, 255},
+			{143, 36, 105, 255},
+			{144, 37, 104, 255},
+			{146, 37, 104, 255},
+			{147, 38, 103, 255},
+			{149, 38, 103, 255},
+			{151, 39, 102, 255},
+			{152, 39, 102, 255},
+			{154, 40, 101, 255},
+			{155, 41, 100, 255},
+			{157, 41, 100, 255},
+			{159, 42, 99, 255},
+			{160, 42, 99, 255},
+			{162, 43, 98, 255},
+			{163, 44, 97, 255},
+			{165, 44, 96, 255},
+			{166, 45, 96, 255},
+			{168, 46, 95, 255},
+			{169, 46, 94, 255},
+			{171, 47, 94, 255},
+			{173, 48, 93, 255},
+			{174, 48, 92, 255},
+			{176, 49, 91, 255},
+			{177, 50, 90, 255},
+			{179, 50, 90, 255},
+			{180, 51, 89, 255},
+			{182, 52, 88, 255},
+			{183, 53, 87, 255},
+			{185, 53, 86, 255},
+			{186, 54, 85, 255},
+			{188, 55, 84, 255},
+			{189, 56, 83, 255},
+			{191, 57, 82, 255},
+			{192, 58, 81, 255},
+			{193, 58, 80, 255},
+			{195, 59, 79, 255},
+			{196, 60, 78, 255},
+			{198, 61, 77, 255},
+			{199, 62, 76, 255},
+			{200, 63, 75, 255},
+			{202, 64, 74, 255},
+			{203, 65, 73, 255},
+			{204, 66, 72, 255},
+			{206, 67, 71, 255},
+			{207, 68, 70, 255},
+			{208, 69, 69, 255},
+			{210, 70, 68, 255},
+			{211, 71, 67, 255},
+			{212, 72, 66, 255},
+			{213, 74, 65, 255},
+			{215, 75, 63, 255},
+			{216, 76, 62, 255},
+			{217, 77, 61, 255},
+			{218, 78, 60, 255},
+			{219, 80, 59, 255},
+			{221, 81, 58, 255},
+			{222, 82, 56, 255},
+			{223, 83, 55, 255},
+			{224, 85, 54, 255},
+			{225, 86, 53, 255},
+			{226, 87, 52, 255},
+			{227, 89, 51, 255},
+			{228, 90, 49, 255},
+			{229, 92, 48, 255},
+			{230, 93, 47, 255},
+			{231, 94, 46, 255},
+			{232, 96, 45, 255},
+			{233, 97, 43, 255},
+			{234, 99, 42, 255},
+			{235, 100, 41, 255},
+			{235, 102, 40, 255},
+			{236, 103, 38, 255},
+			{237, 105, 37, 255},
+			{238, 106, 36, 255},
+			{239, 108, 35, 255},
+			{239, 110, 33, 255},
+			{240, 111, 32, 255},
+			{241, 113, 31, 255},
+			{241, 115, 29, 255},
+			{242, 116, 28, 255},
+			{243, 118, 27, 255},
+			{243, 120, 25, 255},
+			{244, 121, 24, 255},
+			{245, 123, 23, 255},
+			{245, 125, 21, 255},
+			{246, 126, 20, 255},
+			{246, 128, 19, 255},
+			{247, 130, 18, 255},
+			{247, 132, 16, 255},
+			{248, 133, 15, 255},
+			{248, 135, 14, 255},
+			{248, 137, 12, 255},
+			{249, 139, 11, 255},
+			{249, 140, 10, 255},
+			{249, 142, 9, 255},
+			{250, 144, 8, 255},
+			{250, 146, 7, 255},
+			{250, 148, 7, 255},
+			{251, 150, 6, 255},
+			{251, 151, 6, 255},
+			{251, 153, 6, 255},
+			{251, 155, 6, 255},
+			{251, 157, 7, 255},
+			{252, 159, 7, 255},
+			{252, 161, 8, 255},
+			{252, 163, 9, 255},
+			{252, 165, 10, 255},
+			{252, 166, 12, 255},
+			{252, 168, 13, 255},
+			{252, 170, 15, 255},
+			{252, 172, 17, 255},
+			{252, 174, 18, 255},
+			{252, 176, 20, 255},
+			{252, 178, 22, 255},
+			{252, 180, 24, 255},
+			{251, 182, 26, 255},
+			{251, 184, 29, 255},
+			{251, 186, 31, 255},
+			{251, 188, 33, 255},
+			{251, 190, 35, 255},
+			{250, 192, 38, 255},
+			{250, 194, 40, 255},
+			{250, 196, 42, 255},
+			{250, 198, 45, 255},
+			{249, 199, 47, 255},
+			{249, 201, 50, 255},
+			{249, 203, 53, 255},
+			{248, 205, 55, 255},
+			{248, 207, 58, 255},
+			{247, 209, 61, 255},
+			{247, 211, 64, 255},
+			{246, 213, 67, 255},
+			{246, 215, 70, 255},
+			{245, 217, 73, 255},
+			{245, 219, 76, 255},
+			{244, 221, 79, 255},
+			{244, 223, 83, 255},
+			{244, 225, 86, 255},
+			{243, 227, 90, 255},
+			{243, 229, 93, 255},
+			{242, 230, 97, 255},
+			{242, 232, 101, 255},
+			{242, 234, 105, 255},
+			{241, 236, 109, 255},
+			{241, 237, 113, 255},
+			{241, 239, 117, 255},
+			{241, 241, 121, 255},
+			{242, 242, 125, 255},
+			{242, 244, 130, 255},
+			{243, 245, 134, 255},
+			{243, 246, 138, 255},
+			{244, 248, 142, 255},
+			{245, 249, 146, 255},
+			{246, 250, 150, 255},
+			{248, 251, 154, 255},
+			{249, 252, 157, 255},
+			{250, 253, 161, 255},
+			{252, 255, 164, 255},
+		},
+	}
+
+	Plasma = RGBGradient{
+		Colors: []color.RGBA{
+			{13, 8, 135, 255},
+			{16, 7, 136, 255},
+			{19, 7, 137, 255},
+			{22, 7, 138, 255},
+			{25, 6, 140, 255},
+			{27, 6, 141, 255},
+			{29, 6, 142, 255},
+			{32, 6, 143, 255},
+			{34, 6, 144, 255},
+			{36, 6, 145, 255},
+			{38, 5, 145, 255},
+			{40, 5, 146, 255},
+			{42, 5, 147, 255},
+			{44, 5, 148, 255},
+			{46, 5, 149, 255},
+			{47, 5, 150, 255},
+			{49, 5, 151, 255},
+			{51, 5, 151, 255},
+			{53, 4, 152, 255},
+			{55, 4, 153, 255},
+			{56, 4, 154, 255},
+			{58, 4, 154, 255},
+			{60, 4, 155, 255},
+			{62, 4, 156, 255},
+			{63, 4, 156, 255},
+			{65, 4, 157, 255},
+			{67, 3, 158, 255},
+			{68, 3, 158, 255},
+			{70, 3, 159, 255},
+			{72, 3, 159, 255},
+			{73, 3, 160, 255},
+			{75, 3, 161, 255},
+			{76, 2, 161, 255},
+			{78, 2, 162, 255},
+			{80, 2, 162, 255},
+			{81, 2, 163, 255},
+			{83, 2, 163, 255},
+			{85, 2, 164, 255},
+			{86, 1, 164, 255},
+			{88, 1, 164, 255},
+			{89, 1, 165, 255},
+			{91, 1, 165, 255},
+			{92, 1, 166, 255},
+			{94, 1, 166, 255},
+			{96, 1, 166, 255},
+			{97, 0, 167, 255},
+			{99, 0, 167, 255},
+			{100, 0, 167, 255},
+			{102, 0, 167, 255},
+			{103, 0, 168, 255},
+			{105, 0, 168, 255},
+			{106, 0, 168, 255},
+			{108, 0, 168, 255},
+			{110, 0, 168, 255},
+			{111, 0, 168, 255},
+			{113, 0, 168, 255},
+			{114, 1, 168, 255},
+			{116, 1, 168, 255},
+			{117, 1, 168, 255},
+			{119, 1, 168, 255},
+			{120, 1, 168, 255},
+			{122, 2, 168, 255},
+			{123, 2, 168, 255},
+			{125, 3, 168, 255},
+			{126, 3, 168, 255},
+			{128, 4, 168, 255},
+			{129, 4, 167, 255},
+			{131, 5, 167, 255},
+			{132, 5, 167, 255},
+			{134, 6, 166, 255},
+			{135, 7, 166, 255},
+			{136, 8, 166, 255},
+			{138, 9, 165, 255},
+			{139, 10, 165, 255},
+			{141, 11, 165, 255},
+			{142, 12, 164, 255},
+			{143, 13, 164, 255},
+			{145, 14, 163, 255},
+			{146, 15, 163, 255},
+			{148, 16, 162, 255},
+			{149, 17, 161, 255},
+			{150, 19, 161, 255},
+			{152, 20, 160, 255},
+			{153, 21, 159, 255},
+			{154, 22, 159, 255},
+			{156, 23, 158, 255},
+			{157, 24, 157, 255},
+			{158, 25, 157, 255},
+			{160, 26, 156, 255},
+			{161, 27, 155, 255},
+			{162, 29, 154, 255},
+			{163, 30, 154, 255},
+			{165, 31, 153, 255},
+			{166, 32, 152, 255},
+			{167, 33, 151, 255},
+			{168, 34, 150, 255},
+			{170, 35, 149, 255},
+			{171, 36, 148, 255},
+			{172, 38, 148, 255},
+			{173, 39, 147, 255},
+			{174, 40, 146, 255},
+			{176, 41, 145, 255},
+			{177, 42, 144, 255},
+			{178, 43, 143, 255},
+			{179, 44, 142, 255},
+			{180, 46, 141, 255},
+			{181, 47, 140, 255},
+			{182, 48, 139, 255},
+			{183, 49, 138, 255},
+			{184, 50, 137, 255},
+			{186, 51, 136, 255},
+			{187, 52, 136, 255},
+			{188, 53, 135, 255},
+			{189, 55, 134, 255},
+			{190, 56, 133, 255},
+			{191, 57, 132, 255},
+			{192, 58, 131, 255},
+			{193, 59, 130, 255},
+			{194, 60, 129, 255},
+			{195, 61, 128, 255},
+			{196, 62, 127, 255},
+			{197, 64, 126, 255},
+			{198, 65, 125, 255},
+			{199, 66, 124, 255},
+			{200, 67, 123, 255},
+			{201, 68, 122, 255},
+			{202, 69, 122, 255},
+			{203, 70, 121, 255},
+			{204, 71, 120, 255},
+			{204, 73, 119, 255},
+			{205, 74, 118, 255},
+			{206, 75, 117, 255},
+			{207, 76, 116, 255},
+			{208, 77, 115, 255},
+			{209, 78, 114, 255},
+			{210, 79, 113, 255},
+			{211, 81, 113, 255},
+			{212, 82, 112, 255},
+			{213, 83, 111, 255},
+			{213, 84, 110, 255},
+			{214, 85, 109, 255},
+			{215, 86, 108, 255},
+			{216, 87, 107, 255},
+			{217, 88, 106, 255},
+			{218, 90, 106, 255},
+			{218, 91, 105, 255},
+			{219, 92, 104, 255},
+			{220, 93, 103, 255},
+			{221, 94, 102, 255},
+			{222, 95, 101, 255},
+			{222, 97, 100, 255},
+			{223, 98, 99, 255},
+			{224, 99, 99, 255},
+			{225, 100, 98, 255},
+			{226, 101, 97, 255},
+			{226, 102, 96, 255},
+			{227, 104, 95, 255},
+			{228, 105, 94, 255},
+			{229, 106, 93, 255},
+			{229, 107, 93, 255},
+			{230, 108, 92, 255},
+			{231, 110, 91, 255},
+			{231, 111, 90, 255},
+			{232, 112, 89, 255},
+			{233, 113, 88, 255},
+			{233, 114, 87, 255},
+			{234, 116, 87, 255},
+			{235, 117, 86, 255},
+			{235, 118, 85, 255},
+			{236, 119, 84, 255},
+			{237, 121, 83, 255},
+			{237, 122, 82, 255},
+			{238, 123, 81, 255},
+			{239, 124, 81, 255},
+			{239, 126, 80, 255},
+			{240, 127, 79, 255},
+			{240, 128, 78, 255},
+			{241, 129, 77, 255},
+			{241, 131, 76, 255},
+			{242, 132, 75, 255},
+			{243, 133, 75, 255},
+			{243, 135, 74, 255},
+			{244, 136, 73, 255},
+			{244, 137, 72, 255},
+			{245, 139, 71, 255},
+			{245, 140, 70, 255},
+			{246, 141, 69, 255},
+			{246, 143, 68, 255},
+			{247, 144, 68, 255},
+			{247, 145, 67, 255},
+			{247, 147, 66, 255},
+			{248, 148, 65, 255},
+			{248, 149, 64, 255},
+			{249, 151, 63, 255},
+			{249, 152, 62, 255},
+			{249, 154, 62, 255},
+			{250, 155, 61, 255},
+			{250, 156, 60, 255},
+			{250, 158, 59, 255},
+			{251, 159, 58, 255},
+			{251, 161, 57, 255},
+			{251, 162, 56, 255},
+			{252, 163, 56, 255},
+			{252, 165, 55, 255},
+			{252, 166, 54, 255},
+			{252, 168, 53, 255},
+			{252, 169, 52, 255},
+			{253, 171, 51, 255},
+			{253, 172, 51, 255},
+			{253, 174, 50, 255},
+			{253, 175, 49, 255},
+			{253, 177, 48, 255},
+			{253, 178, 47, 255},
+			{253, 180, 47, 255},
+			{253, 181, 46, 255},
+			{254, 183, 45, 255},
+			{254, 184, 44, 255},
+			{254, 186, 44, 255},
+			{254, 187, 43, 255},
+			{254, 189, 42, 255},
+			{254, 190, 42, 255},
+			{254, 192, 41, 255},
+			{253, 194, 41, 255},
+			{253, 195, 40, 255},
+			{253, 197, 39, 255},
+			{253, 198, 39, 255},
+			{253, 200, 39, 255},
+			{253, 202, 38, 255},
+			{253, 203, 38, 255},
+			{252, 205, 37, 255},
+			{252, 206, 37, 255},
+			{252, 208, 37, 255},
+			{252, 210, 37, 255},
+			{251, 211, 36, 255},
+			{251, 213, 36, 255},
+			{251, 215, 36, 255},
+			{250, 216, 36, 255},
+			{250, 218, 36, 255},
+			{249, 220, 36, 255},
+			{249, 221, 37, 255},
+			{248, 223, 37, 255},
+			{248, 225, 37, 255},
+			{247, 226, 37, 255},
+			{247, 228, 37, 255},
+			{246, 230, 38, 255},
+			{246, 232, 38, 255},
+			{245, 233, 38, 255},
+			{245, 235, 39, 255},
+			{244, 237, 39, 255},
+			{243, 238, 39, 255},
+			{243, 240, 39, 255},
+			{242, 242, 39, 255},
+			{241, 244, 38, 255},
+			{241, 245, 37, 255},
+			{240, 247, 36, 255},
+			{240, 249, 33, 255},
+		},
+	}
+
+	Cividis = RGBGradient{
+		Colors: []color.RGBA{
+			{0, 34, 78, 255},
+			{0, 35, 79, 255},
+			{0, 36, 81, 255},
+			{0, 37, 83, 255},
+			{0, 37, 84, 255},
+			{0, 38, 86, 255},
+			{0, 39, 88, 255},
+			{0, 40, 89, 255},
+			{0, 40, 91, 255},
+			{0, 41, 93, 255},
+			{0, 42, 95, 255},
+			{0, 42, 97, 255},
+			{0, 43, 98, 255},
+			{0, 44, 100, 255},
+			{0, 44, 102, 255},
+			{0, 45, 104, 255},
+			{0, 46, 106, 255},
+			{0, 46, 108, 255},
+			{0, 47, 109, 255},
+			{0, 48, 111, 255},
+			{0, 48, 112, 255},
+			{0, 49, 112, 255},
+			{0, 49, 113, 255},
+			{1, 50, 113, 255},
+			{5, 51, 113, 255},
+			{8, 51, 112, 255},
+			{12, 52, 112, 255},
+			{15, 53, 112, 255},
+			{18, 53, 112, 255},
+			{20, 54, 112, 255},
+			{22, 55, 112, 255},
+			{24, 55, 111, 255},
+			{26, 56, 111, 255},
+			{28, 57, 111, 255},
+			{30, 58, 111, 255},
+			{32, 58, 111, 255},
+			{33, 59, 110, 255},
+			{35, 60, 110, 255},
+			{36, 60, 110, 255},
+			{38, 61, 110, 255},
+			{39, 62, 110, 255},
+			{41, 63, 110, 255},
+			{42, 63, 109, 255},
+			{43, 64, 109, 255},
+			{45, 65, 109, 255},
+			{46, 65, 109, 255},
+			{47, 66, 109, 255},
+			{49, 67, 109, 255},
+			{50, 67, 109, 255},
+			{51, 68, 109, 255},
+			{52, 69, 108, 255},
+			{53, 69, 108, 255},
+			{54, 70, 108, 255},
+			{56, 71, 108, 255},
+			{57, 72, 108, 255},
+			{58, 72, 108, 255},
+			{59, 73, 108, 255},
+			{60, 74, 108, 255},
+			{61, 74, 108, 255},
+			{62, 75, 108, 255},
+			{63, 76, 108, 255},
+			{64, 76, 108, 255},
+			{65, 77, 108, 255},
+			{66, 78, 108, 255},
+			{67, 78, 108, 255},
+			{68, 79, 108, 255},
+			{69, 80, 108, 255},
+			{70, 81, 108, 255},
+			{71, 81, 108, 255},
+			{72, 82, 108, 255},
+			{73, 83, 108, 255},
+			{74, 83, 108, 255},
+			{75, 84, 108, 255},
+			{76, 85, 108, 255},
+			{77, 85, 108, 255},
+			{78, 86, 108, 255},
+			{79, 87, 108, 255},
+			{80, 87, 108, 255},
+			{81, 88, 109, 255},
+			{82, 89, 109, 255},
+			{83, 90, 109, 255},
+			{84, 90, 109, 255},
+			{85, 91, 109, 255},
+			{85, 92, 109, 255},
+			{86, 92, 109, 255},
+			{87, 93, 109, 255},
+			{88, 94, 109, 255},
+			{89, 94, 110, 255},
+			{90, 95, 110, 255},
+			{91, 96, 110, 255},
+			{92, 97, 110, 255},
+			{93, 97, 110, 255},
+			{94, 98, 110, 255},
+			{94, 99, 111, 255},
+			{95, 99, 111, 255},
+			{96, 100, 111, 255},
+			{97, 101, 111, 255},
+			{98, 101, 111, 255},
+			{99, 102, 112, 255},
+			{100, 103, 112, 255},
+			{101, 104, 112, 255},
+			{101, 104, 112, 255},
+			{102, 105, 112, 255},
+			{103, 106, 113, 255},
+			{104, 106, 113, 255},
+			{105, 107, 113, 255},
+			{106, 108, 113, 255},
+			{107, 109, 114, 255},
+			{108, 109, 114, 255},
+			{108, 110, 114, 255},
+			{109, 111, 114, 255},
+			{110, 111, 115, 255},
+			{111, 112, 115, 255},
+			{112, 113, 115, 255},
+			{113, 114, 116, 255},
+			{114, 114, 116, 255},
+			{114, 115, 116, 255},
+			{115, 116, 117, 255},
+			{116, 116, 117, 255},
+			{117, 117, 117, 255},
+			{118, 118, 118, 255},
+			{119, 119, 118, 255},
+			{119, 119, 119, 255},
+			{120, 120, 119, 255},
+			{121, 121, 119, 255},
+			{122, 122, 120, 255},
+			{123, 122, 120, 255},
+			{124, 123, 120, 255},
+			{125, 124, 120, 255},
+			{126, 124, 120, 255},
+			{126, 125, 120, 255},
+			{127, 126, 120, 255},
+			{128, 127, 120, 255},
+			{129, 127, 120, 255},
+			{130, 128, 121, 255},
+			{131, 129, 121, 255},
+			{132, 130, 121, 255},
+			{133, 130, 121, 255},
+			{134, 131, 121, 255},
+			{135, 132, 120, 255},
+			{136, 133, 120, 255},
+			{137, 133, 120, 255},
+			{138, 134, 120, 255},
+			{139, 135, 120, 255},
+			{140, 136, 120, 255},
+			{141, 136, 120, 255},
+			{142, 137, 120, 255},
+			{143, 138, 120, 255},
+			{144, 139, 120, 255},
+			{145, 139, 120, 255},
+			{146, 140, 120, 255},
+			{146, 141, 120, 255},
+			{147, 142, 120, 255},
+			{148, 142, 119, 255},
+			{149, 143, 119, 255},
+			{150, 144, 119, 255},
+			{151, 145, 119, 255},
+			{152, 146, 119, 255},
+			{153, 146, 119, 255},
+			{154, 147, 118, 255},
+			{155, 148, 118, 255},
+			{156, 149, 118, 255},
+			{157, 149, 118, 255},
+			{158, 150, 118, 255},
+			{159, 151, 117, 255},
+			{160, 152, 117, 255},
+			{161, 153, 117, 255},
+			{162, 153, 117, 255},
+			{163, 154, 116, 255},
+			{164, 155, 116, 255},
+			{165, 156, 116, 255},
+			{166, 156, 116, 255},
+			{167, 157, 115, 255},
+			{168, 158, 115, 255},
+			{169, 159, 115, 255},
+			{170, 160, 115, 255},
+			{171, 160, 114, 255},
+			{172, 161, 114, 255},
+			{173, 162, 114, 255},
+			{174, 163, 113, 255},
+			{175, 164, 113, 255},
+			{176, 165, 113, 255},
+			{177, 165, 112, 255},
+			{179, 166, 112, 255},
+			{180, 167, 111, 255},
+			{181, 168, 111, 255},
+			{182, 169, 111, 255},
+			{183, 169, 110, 255},
+			{184, 170, 110, 255},
+			{185, 171, 109, 255},
+			{186, 172, 109, 255},
+			{187, 173, 109, 255},
+			{188, 174, 108, 255},
+			{189, 174, 108, 255},
+			{190, 175, 107, 255},
+			{191, 176, 107, 255},
+			{192, 177, 106, 255},
+			{193, 178, 106, 255},
+			{194, 179, 105, 255},
+			{195, 179, 105, 255},
+			{196, 180, 104, 255},
+			{197, 181, 104, 255},
+			{198, 182, 103, 255},
+			{199, 183, 103, 255},
+			{200, 184, 102, 255},
+			{201, 185, 101, 255},
+			{203, 185, 101, 255},
+			{204, 186, 100, 255},
+			{205, 187, 99, 255},
+			{206, 188, 99, 255},
+			{207, 189, 98, 255},
+			{208, 190, 98, 255},
+			{209, 191, 97, 255},
+			{210, 192, 96, 255},
+			{211, 192, 95, 255},
+			{212, 193, 95, 255},
+			{213, 194, 94, 255},
+			{214, 195, 93, 255},
+			{215, 196, 92, 255},
+			{217, 197, 92, 255},
+			{218, 198, 91, 255},
+			{219, 199, 90, 255},
+			{220, 200, 89, 255},
+			{221, 200, 88, 255},
+			{222, 201, 88, 255},
+			{223, 202, 87, 255},
+			{224, 203, 86, 255},
+			{225, 204, 85, 255},
+			{226, 205, 84, 255},
+			{228, 206, 83, 255},
+			{229, 207, 82, 255},
+			{230, 208, 81, 255},
+			{231, 209, 80, 255},
+			{232, 210, 79, 255},
+			{233, 211, 78, 255},
+			{234, 211, 76, 255},
+			{235, 212, 75, 255},
+			{237, 213, 74, 255},
+			{238, 214, 73, 255},
+			{239, 215, 72, 255},
+			{240, 216, 70, 255},
+			{241, 217, 69, 255},
+			{242, 218, 68, 255},
+			{243, 219, 66, 255},
+			{245, 220, 65, 255},
+			{246, 221, 63, 255},
+			{247, 222, 62, 255},
+			{248, 223, 60, 255},
+			{249, 224, 58, 255},
+			{251, 225, 56, 255},
+			{252, 226, 54, 255},
+			{253, 227, 52, 255},
+			{254, 228, 52, 255},
+			{254, 229, 53, 255},
+			{254, 230, 54, 255},
+			{254, 232, 56, 255},
+		},
+	}
+
+	RegisterColormap("magma", Magma)
+	RegisterColormap("inferno", Inferno)
+	RegisterColormap("plasma", Plasma)
+	RegisterColormap("cividis", Cividis)
+}
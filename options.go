@@ -0,0 +1,297 @@
+package clean
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"time"
+)
+
+const (
+	defaultGain          = 0.1
+	defaultNumScales     = 5
+	defaultThreshold     = 1e-5
+	defaultMaxIterations = 50
+	defaultSupportCutoff = 1e-6
+)
+
+// Fields is a set of Options fields, used by Options.Set.
+type Fields uint
+
+// Fields whose zero value is meaningful, for Options.Set.
+const (
+	SetThreshold Fields = 1 << iota
+	SetMaxIterations
+	SetAbsolutePeak
+	SetPositiveOnly
+	SetNoiseThreshold
+	SetFluxTolerance
+	SetStopOnDivergence
+	SetTimeLimit
+	SetAutoMaskSigma
+	SetStartModel
+	SetFloat32
+	SetSupportCutoff
+)
+
+// Options controls how a deconvolver selects and subtracts components. Zero
+// fields take the value from DefaultOptions, or from the options the
+// deconvolver was constructed with when passed to Deconvolve, and a true
+// boolean is never turned off. A field listed in Set is applied even when it
+// is zero or false: Options{Set: SetThreshold} cleans down to a threshold of
+// zero rather than the default 1e-5, and Options{Set: SetPositiveOnly} clears
+// a PositiveOnly the cleaner was constructed with.
+type Options struct {
+	// Gain is the fraction of the peak removed per iteration, in (0, 1].
+	Gain          float64
+	Threshold     float64
+	MaxIterations int
+	// NumScales selects the default scale sizes 1+2s pixels for s below
+	// NumScales. It is ignored when ScaleSizes is set.
+	NumScales int
+	// ScaleSizes lists the Gaussian sigma of each scale in pixels. A size of
+	// zero is a point component.
+	ScaleSizes []float64
+	// ScaleBias returns the weight applied to each scale's residual peak when
+	// choosing the scale of the next component. It defaults to SqrtScaleBias.
+	ScaleBias func(sizes []float64) []float64
+	// AbsolutePeak searches residuals on absolute value so that negative
+	// sidelobes and residuals are cleaned with signed components.
+	AbsolutePeak bool
+	// PositiveOnly keeps the model non-negative, as wanted for total
+	// intensity: cleaning stops at the first negative component. Without
+	// AbsolutePeak only positive peaks are cleaned anyway, so it matters
+	// together with AbsolutePeak.
+	PositiveOnly bool
+	// NoiseThreshold stops cleaning once the peak falls below this many
+	// times the residual noise, estimated from the median absolute deviation.
+	NoiseThreshold float64
+	// FluxTolerance stops cleaning once the model flux changes by less than
+	// this fraction over the last ten components.
+	FluxTolerance float64
+	// StopOnDivergence stops cleaning when the residual RMS keeps rising.
+	StopOnDivergence bool
+	// TimeLimit bounds the wall-clock time spent iterating.
+	TimeLimit time.Duration
+	// AutoMaskSigma, when set, restricts components to an automatic mask
+	// grown from residual peaks above this many times the residual noise.
+	// The mask is extended between major cycles and never leaves the user
+	// mask.
+	AutoMaskSigma float64
+	// StartModel is subtracted from the dirty image before cleaning and is
+	// included in the returned model and components. Every component must
+	// lie inside the image.
+	StartModel []Component
+	// Beam overrides the restoring beam fitted to the PSF when BMaj is set.
+	Beam Beam
+	// WCS places the image on the sky when its Cell is set. The
+	// deconvolvers do not use it; CleanImage attaches it to the result,
+	// defaulting to a 1 mas SIN grid centred on the image.
+	WCS WCS
+	// SupportCutoff truncates the PSF and scale kernels where they fall
+	// below this fraction of their peak, so that subtracting a component
+	// only updates the window around it. Zero keeps the kernels whole; list
+	// SetSupportCutoff in Set to apply it.
+	SupportCutoff float64
+	// Float32 keeps the multi-scale beams and residual maps in single
+	// precision, halving the memory they hold. Convolutions are still
+	// computed in double precision, one scale at a time, and the model
+	// stays in double precision.
+	Float32 bool
+	// Workers bounds the goroutines used by each parallel stage. It
+	// defaults to GOMAXPROCS.
+	Workers int
+	// Context, when set, cancels a run. Cleaning stops with StopCancelled;
+	// stages before the first component, which have no partial result,
+	// return the context's error instead.
+	Context context.Context
+	// TaskTimer, when set, is called with the timing of every parallel task.
+	// Calls are never concurrent.
+	TaskTimer func(TaskTiming)
+	// Set lists the fields applied even when zero.
+	Set Fields
+
+	// pool, when set, is the worker pool of the run these options belong
+	// to, so that every stage of the run shares it.
+	pool *workerPool
+}
+
+// DefaultOptions returns the settings used for fields left zero.
+func DefaultOptions() Options {
+	return Options{
+		Gain:          defaultGain,
+		Threshold:     defaultThreshold,
+		MaxIterations: defaultMaxIterations,
+		NumScales:     defaultNumScales,
+		ScaleBias:     SqrtScaleBias,
+		SupportCutoff: defaultSupportCutoff,
+		Workers:       runtime.GOMAXPROCS(0),
+	}
+}
+
+// SqrtScaleBias weights scale s by 1/sqrt(s+1).
+func SqrtScaleBias(sizes []float64) []float64 {
+	bias := make([]float64, len(sizes))
+	for s := range bias {
+		bias[s] = 1.0 / math.Sqrt(float64(s+1))
+	}
+	return bias
+}
+
+// CornwellScaleBias returns the small-scale bias of Cornwell (2008), which
+// weights a scale of size r by 1 - b*r/rmax.
+func CornwellScaleBias(b float64) func(sizes []float64) []float64 {
+	return func(sizes []float64) []float64 {
+		rmax := 0.0
+		for _, r := range sizes {
+			rmax = math.Max(rmax, r)
+		}
+		bias := make([]float64, len(sizes))
+		for s, r := range sizes {
+			bias[s] = 1
+			if rmax > 0 {
+				bias[s] -= b * r / rmax
+			}
+		}
+		return bias
+	}
+}
+
+// Validate reports the first setting in o that is out of range. Zero values
+// are valid and mean "use the default" unless the field is listed in Set.
+func (o Options) Validate() error {
+	switch {
+	case o.Gain < 0 || o.Gain > 1:
+		return fmt.Errorf("gain %g must be in (0, 1]", o.Gain)
+	case o.Threshold < 0:
+		return fmt.Errorf("threshold %g must not be negative", o.Threshold)
+	case o.MaxIterations < 0:
+		return fmt.Errorf("maximum iterations %d must not be negative", o.MaxIterations)
+	case o.NumScales < 0:
+		return fmt.Errorf("number of scales %d must not be negative", o.NumScales)
+	case o.NoiseThreshold < 0:
+		return fmt.Errorf("noise threshold %g must not be negative", o.NoiseThreshold)
+	case o.FluxTolerance < 0:
+		return fmt.Errorf("flux tolerance %g must not be negative", o.FluxTolerance)
+	case o.AutoMaskSigma < 0:
+		return fmt.Errorf("auto-mask sigma %g must not be negative", o.AutoMaskSigma)
+	case o.SupportCutoff < 0 || o.SupportCutoff >= 1:
+		return fmt.Errorf("support cutoff %g must be in [0, 1)", o.SupportCutoff)
+	case o.Workers < 0:
+		return fmt.Errorf("workers %d must not be negative", o.Workers)
+	case o.TimeLimit < 0:
+		return fmt.Errorf("time limit %v must not be negative", o.TimeLimit)
+	case o.Beam.BMaj < 0 || o.Beam.BMin < 0 || o.Beam.BMin > o.Beam.BMaj:
+		return fmt.Errorf("beam %v must have 0 <= BMIN <= BMAJ", o.Beam)
+	case o.WCS != WCS{}:
+		if err := o.WCS.Validate(); err != nil {
+			return err
+		}
+	}
+	for i, c := range o.StartModel {
+		if c.X < 0 || c.Y < 0 || c.Size < 0 || math.IsNaN(c.Flux) || math.IsInf(c.Flux, 0) || math.IsNaN(c.Size) || math.IsInf(c.Size, 0) {
+			return fmt.Errorf("starting model component %d must have a finite flux and size at a non-negative pixel, got %+v", i, c)
+		}
+	}
+	for _, r := range o.ScaleSizes {
+		if r < 0 || math.IsNaN(r) || math.IsInf(r, 0) {
+			return fmt.Errorf("scale size %g must be a finite, non-negative number of pixels", r)
+		}
+	}
+	return nil
+}
+
+// overlay returns o with every non-zero field of other, and every field
+// listed in other.Set, applied on top.
+func (o Options) overlay(other Options) Options {
+	set := func(f Fields) bool { return other.Set&f != 0 }
+	if other.Gain > 0 {
+		o.Gain = other.Gain
+	}
+	if other.Threshold > 0 || set(SetThreshold) {
+		o.Threshold = other.Threshold
+	}
+	if other.MaxIterations > 0 || set(SetMaxIterations) {
+		o.MaxIterations = other.MaxIterations
+	}
+	if other.NumScales > 0 {
+		o.NumScales = other.NumScales
+		o.ScaleSizes = nil
+	}
+	if len(other.ScaleSizes) > 0 {
+		o.ScaleSizes = other.ScaleSizes
+	}
+	if other.ScaleBias != nil {
+		o.ScaleBias = other.ScaleBias
+	}
+	if other.NoiseThreshold > 0 || set(SetNoiseThreshold) {
+		o.NoiseThreshold = other.NoiseThreshold
+	}
+	if other.FluxTolerance > 0 || set(SetFluxTolerance) {
+		o.FluxTolerance = other.FluxTolerance
+	}
+	if other.AutoMaskSigma > 0 || set(SetAutoMaskSigma) {
+		o.AutoMaskSigma = other.AutoMaskSigma
+	}
+	if other.TimeLimit > 0 || set(SetTimeLimit) {
+		o.TimeLimit = other.TimeLimit
+	}
+	if other.AbsolutePeak || set(SetAbsolutePeak) {
+		o.AbsolutePeak = other.AbsolutePeak
+	}
+	if other.PositiveOnly || set(SetPositiveOnly) {
+		o.PositiveOnly = other.PositiveOnly
+	}
+	if other.StopOnDivergence || set(SetStopOnDivergence) {
+		o.StopOnDivergence = other.StopOnDivergence
+	}
+	if other.Float32 || set(SetFloat32) {
+		o.Float32 = other.Float32
+	}
+	if len(other.StartModel) > 0 || set(SetStartModel) {
+		o.StartModel = other.StartModel
+	}
+	if other.Beam.BMaj > 0 {
+		o.Beam = other.Beam
+	}
+	if other.WCS.Cell > 0 {
+		o.WCS = other.WCS
+	}
+	if other.SupportCutoff > 0 || set(SetSupportCutoff) {
+		o.SupportCutoff = other.SupportCutoff
+	}
+	if other.Workers > 0 {
+		o.Workers = other.Workers
+	}
+	if other.Context != nil {
+		o.Context = other.Context
+	}
+	if other.TaskTimer != nil {
+		o.TaskTimer = other.TaskTimer
+	}
+	if other.pool != nil {
+		o.pool = other.pool
+	}
+	return o
+}
+
+// resolve validates o and fills its zero fields from base.
+func (o Options) resolve(base Options) (Options, error) {
+	if err := o.Validate(); err != nil {
+		return Options{}, err
+	}
+	return base.overlay(o), nil
+}
+
+// scaleSizes returns the sigma of every scale in pixels.
+func (o Options) scaleSizes() []float64 {
+	if len(o.ScaleSizes) > 0 {
+		return o.ScaleSizes
+	}
+	sizes := make([]float64, o.NumScales)
+	for s := range sizes {
+		sizes[s] = 1.0 + float64(s)*2.0
+	}
+	return sizes
+}
@@ -0,0 +1,73 @@
+package clean
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestValidateRejectsOutOfRange(t *testing.T) {
+	for _, tc := range []struct {
+		opts Options
+		want string
+	}{
+		{Options{Gain: -0.1}, "gain"},
+		{Options{Gain: 1.5}, "gain"},
+		{Options{Threshold: -1}, "threshold"},
+		{Options{MaxIterations: -1}, "maximum iterations"},
+		{Options{NumScales: -2}, "number of scales"},
+		{Options{NoiseThreshold: -3}, "noise threshold"},
+		{Options{FluxTolerance: -0.01}, "flux tolerance"},
+		{Options{AutoMaskSigma: -5}, "auto-mask sigma"},
+		{Options{SupportCutoff: 1}, "support cutoff"},
+		{Options{Workers: -1}, "workers"},
+		{Options{TimeLimit: -1}, "time limit"},
+		{Options{Beam: Beam{BMaj: 2, BMin: 3}}, "beam"},
+		{Options{ScaleSizes: []float64{0, -1}}, "scale size"},
+		{Options{ScaleSizes: []float64{math.NaN()}}, "scale size"},
+		{Options{ScaleSizes: []float64{math.Inf(1)}}, "scale size"},
+		{Options{WCS: WCS{Cell: -Milliarcsecond}}, ""},
+	} {
+		err := tc.opts.Validate()
+		if err == nil {
+			t.Errorf("Validate(%+v) succeeded", tc.opts)
+			continue
+		}
+		if !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Validate(%+v) = %q, want it to mention %q", tc.opts, err, tc.want)
+		}
+	}
+	if err := (Options{}).Validate(); err != nil {
+		t.Errorf("Validate of zero options: %v", err)
+	}
+	if err := DefaultOptions().Validate(); err != nil {
+		t.Errorf("Validate of default options: %v", err)
+	}
+}
+
+func TestOverlayAppliesSetFields(t *testing.T) {
+	base, err := Options{Threshold: 0.5, PositiveOnly: true, AbsolutePeak: true}.resolve(DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept, err := Options{}.resolve(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept.Threshold != 0.5 || !kept.PositiveOnly || !kept.AbsolutePeak {
+		t.Errorf("zero options changed the base to %+v", kept)
+	}
+
+	got, err := Options{Set: SetThreshold | SetPositiveOnly}.resolve(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Threshold != 0 || got.PositiveOnly || !got.AbsolutePeak {
+		t.Errorf("threshold %g, positive-only %v, absolute peak %v; want 0, false, true",
+			got.Threshold, got.PositiveOnly, got.AbsolutePeak)
+	}
+	if got.Gain != defaultGain || got.MaxIterations != defaultMaxIterations {
+		t.Errorf("gain %g and iterations %d, want the defaults", got.Gain, got.MaxIterations)
+	}
+}
@@ -0,0 +1,207 @@
+package clean
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TaskTiming reports how long one task of a parallel stage took.
+type TaskTiming struct {
+	Stage    string
+	Task     int
+	Worker   int
+	Duration time.Duration
+}
+
+// workerPool runs the parallel stages of a deconvolution with at most workers
+// goroutines. A pool is built once from the options of a run and shared by
+// every stage of it; each call to run waits for its own tasks, so the pool
+// may be used from several goroutines, but a task must not call run itself.
+//
+// Between start and stop the pool keeps its workers running and hands each
+// stage to them over a channel, so stages repeated every iteration start no
+// goroutines and do not allocate. Outside that window every stage starts its
+// own goroutines.
+type workerPool struct {
+	workers int
+	ctx     context.Context
+	timer   func(TaskTiming)
+	timerMu sync.Mutex
+
+	mu      sync.Mutex
+	users   int
+	work    chan *batch
+	batches sync.Pool
+}
+
+// batch is one stage handed to the persistent workers.
+type batch struct {
+	ctx   context.Context
+	stage string
+	n     int
+	task  func(i int)
+	next  atomic.Int64
+	done  sync.WaitGroup
+}
+
+// newWorkerPool returns a pool sized by opts.Workers, or GOMAXPROCS when that
+// is zero, and never smaller than one worker.
+func newWorkerPool(opts Options) *workerPool {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	p := &workerPool{
+		workers: max(1, workers),
+		ctx:     ctx,
+		timer:   opts.TaskTimer,
+	}
+	p.batches.New = func() any { return new(batch) }
+	return p
+}
+
+// start launches the persistent workers if they are not already running.
+// Every call must be matched by a call to stop.
+func (p *workerPool) start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.users++
+	if p.users > 1 || p.workers == 1 {
+		return
+	}
+	p.work = make(chan *batch, p.workers)
+	for w := 0; w < p.workers; w++ {
+		go p.serve(p.work, w)
+	}
+}
+
+// stop ends the persistent workers once every start has been matched.
+func (p *workerPool) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.users--
+	if p.users == 0 && p.work != nil {
+		close(p.work)
+		p.work = nil
+	}
+}
+
+// serve runs the batches sent to a persistent worker until work is closed.
+func (p *workerPool) serve(work <-chan *batch, worker int) {
+	for b := range work {
+		p.drain(b.ctx, b.stage, b.n, &b.next, b.task, worker)
+		b.done.Done()
+	}
+}
+
+// workerPool returns the pool a run with options o uses: the pool it shares
+// with an earlier stage of the run, or a new one.
+func (o Options) workerPool() *workerPool {
+	if o.pool != nil {
+		return o.pool
+	}
+	return newWorkerPool(o)
+}
+
+// run calls task(i) for every i in [0, n), spreading the calls over the
+// workers. Tasks must write disjoint data. Once the pool's context is done no
+// further tasks are started and its error is returned.
+func (p *workerPool) run(stage string, n int, task func(i int)) error {
+	return p.execute(p.ctx, stage, n, task)
+}
+
+// runAll is run for stages that must not be left half done, such as the
+// residual updates of a single component. It ignores cancellation.
+func (p *workerPool) runAll(stage string, n int, task func(i int)) {
+	p.execute(context.Background(), stage, n, task)
+}
+
+func (p *workerPool) execute(ctx context.Context, stage string, n int, task func(i int)) error {
+	if p.workers == 1 || n == 1 {
+		// Run in the caller's goroutine.
+		for i := 0; i < n && ctx.Err() == nil; i++ {
+			start := time.Now()
+			task(i)
+			p.report(TaskTiming{Stage: stage, Task: i, Duration: time.Since(start)})
+		}
+		return ctx.Err()
+	}
+	p.mu.Lock()
+	work := p.work
+	p.mu.Unlock()
+	workers := min(p.workers, n)
+	if work != nil {
+		b := p.batches.Get().(*batch)
+		b.ctx, b.stage, b.n, b.task = ctx, stage, n, task
+		b.next.Store(0)
+		b.done.Add(workers)
+		for w := 0; w < workers; w++ {
+			work <- b
+		}
+		b.done.Wait()
+		b.ctx, b.task = nil, nil
+		p.batches.Put(b)
+		return ctx.Err()
+	}
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(worker int) {
+			defer wg.Done()
+			p.drain(ctx, stage, n, &next, task, worker)
+		}(w)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// drain runs tasks of a stage, claiming each index from next, until every
+// task has been claimed or ctx is done.
+func (p *workerPool) drain(ctx context.Context, stage string, n int, next *atomic.Int64, task func(i int), worker int) {
+	for ctx.Err() == nil {
+		i := int(next.Add(1)) - 1
+		if i >= n {
+			return
+		}
+		start := time.Now()
+		task(i)
+		p.report(TaskTiming{Stage: stage, Task: i, Worker: worker, Duration: time.Since(start)})
+	}
+}
+
+// report passes a timing to the caller's timer, one call at a time.
+func (p *workerPool) report(t TaskTiming) {
+	if p.timer == nil {
+		return
+	}
+	p.timerMu.Lock()
+	defer p.timerMu.Unlock()
+	p.timer(t)
+}
+
+// divide splits [0, total) into one contiguous range per worker.
+func (p *workerPool) divide(total int) [][2]int {
+	chunks := make([][2]int, 0, p.workers)
+	chunkSize := total / p.workers
+	remainder := total % p.workers
+
+	start := 0
+	for i := 0; i < p.workers && start < total; i++ {
+		size := chunkSize
+		if i < remainder {
+			size++
+		}
+		chunks = append(chunks, [2]int{start, start + size})
+		start += size
+	}
+	return chunks
+}
@@ -0,0 +1,117 @@
+package clean
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWorkerPoolRunsEveryTask(t *testing.T) {
+	for _, persistent := range []bool{false, true} {
+		for _, workers := range []int{0, 1, 3, 16} {
+			var timings []TaskTiming
+			p := newWorkerPool(Options{Workers: workers, TaskTimer: func(tt TaskTiming) {
+				timings = append(timings, tt)
+			}})
+			if p.workers < 1 {
+				t.Fatalf("Workers %d: pool has %d workers", workers, p.workers)
+			}
+			if persistent {
+				// A second start shares the workers of the first.
+				p.start()
+				p.start()
+			}
+			done := make([]int, 10)
+			for stage := 0; stage < 3; stage++ {
+				if err := p.run("test", len(done), func(i int) { done[i]++ }); err != nil {
+					t.Fatalf("Workers %d, persistent %v: %v", workers, persistent, err)
+				}
+			}
+			if persistent {
+				p.stop()
+				p.stop()
+				if p.work != nil {
+					t.Errorf("Workers %d: workers still running after stop", workers)
+				}
+			}
+			for i, n := range done {
+				if n != 3 {
+					t.Errorf("Workers %d, persistent %v: task %d ran %d times in 3 stages", workers, persistent, i, n)
+				}
+			}
+			if len(timings) != 3*len(done) {
+				t.Errorf("Workers %d, persistent %v: got %d timings, want %d", workers, persistent, len(timings), 3*len(done))
+			}
+			for _, tt := range timings {
+				if tt.Stage != "test" || tt.Worker < 0 || tt.Worker >= p.workers {
+					t.Errorf("Workers %d, persistent %v: unexpected timing %+v", workers, persistent, tt)
+				}
+			}
+		}
+	}
+}
+
+func TestWorkerPoolCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := newWorkerPool(Options{Workers: 1, Context: ctx})
+	ran := 0
+	err := p.run("test", 10, func(i int) {
+		ran++
+		if i == 2 {
+			cancel()
+		}
+	})
+	if err != context.Canceled {
+		t.Errorf("run returned %v, want %v", err, context.Canceled)
+	}
+	if ran != 3 {
+		t.Errorf("ran %d tasks after cancelling at the third, want 3", ran)
+	}
+}
+
+func TestDeconvolveStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	psf := createPSFFromACB(16)
+	dirty := copyImage(psf)
+	for _, name := range Deconvolvers() {
+		d, err := NewDeconvolver(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := d.Deconvolve(dirty, psf, nil, Options{Context: ctx})
+		switch {
+		case err != nil && err != context.Canceled:
+			t.Errorf("%s: %v", name, err)
+		case err == nil && result.StopReason != StopCancelled:
+			t.Errorf("%s: stopped with %v, want %v", name, result.StopReason, StopCancelled)
+		}
+	}
+}
+
+func TestCleanACBDataSharesPool(t *testing.T) {
+	data, err := ParseACB(testACB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stages := make(map[string]bool)
+	opts := Options{MaxIterations: 5, NumScales: 2, Workers: 2, TaskTimer: func(tt TaskTiming) {
+		stages[tt.Stage] = true
+		if tt.Worker >= 2 {
+			t.Errorf("stage %s ran on worker %d of 2", tt.Stage, tt.Worker)
+		}
+	}}
+	if _, err := CleanACBData(data, "multiscale", 32, nil, opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, stage := range []string{"dirty maps", "scale PSFs", "update residuals"} {
+		if !stages[stage] {
+			t.Errorf("no timings for stage %s", stage)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := CleanACBData(data, "multiscale", 32, nil, Options{Context: ctx}); err != context.Canceled {
+		t.Errorf("cancelled run returned %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,118 @@
+package clean
+
+import (
+	"math"
+	"testing"
+	"unsafe"
+)
+
+// syntheticSky returns a point source and an extended Gaussian source on an
+// n×n image, convolved with a Gaussian PSF.
+func syntheticSky(n int) (dirty, psf Image) {
+	sky := newImage(n, n)
+	sky[n/2][n/2] = 5
+	for x := range sky {
+		for y := range sky[x] {
+			dx, dy := float64(x-n/4), float64(y-n/3)
+			sky[x][y] += 0.5 * math.Exp(-(dx*dx+dy*dy)/(2*4*4))
+		}
+	}
+	psf = newImage(n, n)
+	for x := range psf {
+		for y := range psf[x] {
+			dx, dy := float64(x-n/2), float64(y-n/2)
+			psf[x][y] = math.Exp(-(dx*dx + dy*dy) / (2 * 1.5 * 1.5))
+		}
+	}
+	return convolveSame(sky, psf), psf
+}
+
+func TestFloat32MatchesFloat64(t *testing.T) {
+	dirty, psf := syntheticSky(64)
+	opts := Options{MaxIterations: 200, ScaleSizes: []float64{0, 2, 4}}
+	msc, err := NewMultiScaleCleaner(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := msc.Deconvolve(dirty, psf, nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := msc.Deconvolve(dirty, psf, nil, Options{Float32: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Components) != len(want.Components) {
+		t.Fatalf("float32 found %d components, float64 %d", len(got.Components), len(want.Components))
+	}
+	for i, c := range got.Components {
+		w := want.Components[i]
+		if c.X != w.X || c.Y != w.Y || c.Scale != w.Scale {
+			t.Fatalf("component %d at (%d, %d) scale %d, float64 at (%d, %d) scale %d",
+				i, c.X, c.Y, c.Scale, w.X, w.Y, w.Scale)
+		}
+	}
+
+	gotFlux, wantFlux := 0.0, 0.0
+	for i := range got.Components {
+		gotFlux += got.Components[i].Flux
+		wantFlux += want.Components[i].Flux
+	}
+	fluxErr := math.Abs(gotFlux-wantFlux) / wantFlux
+	residualErr := maxAbsDiff(got.Residual, want.Residual) / maxValue(dirty)
+	t.Logf("relative model flux difference %.2g, residual difference %.2g of the dirty peak", fluxErr, residualErr)
+	if fluxErr > 1e-5 {
+		t.Errorf("model flux differs by %g relative", fluxErr)
+	}
+	if residualErr > 1e-5 {
+		t.Errorf("residual differs by %g of the dirty peak", residualErr)
+	}
+}
+
+// gridBytes returns the storage behind the pixels of g.
+func gridBytes[T Float](g GridOf[T]) int {
+	var zero T
+	return len(g.Data) * int(unsafe.Sizeof(zero))
+}
+
+// multiScaleBytes returns the bytes held by the beams and the workspace of a
+// multi-scale run in precision T on dirty.
+func multiScaleBytes[T Float](t *testing.T, msc *MultiScaleCleaner, dirty Image) int {
+	t.Helper()
+	beams, err := multiScaleBeams[T](msc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for s := range beams.scale {
+		total += gridBytes(beams.scale[s])
+		for u := s; u < len(beams.cross[s]); u++ {
+			total += gridBytes(beams.cross[s][u])
+		}
+	}
+	ws := newMSWorkspace(msc, beams, GridFromImage(dirty))
+	total += gridBytes(ws.residual)
+	for _, g := range ws.smoothed {
+		total += gridBytes(g)
+	}
+	return total
+}
+
+func TestFloat32HalvesBeamMemory(t *testing.T) {
+	dirty, psf := syntheticSky(64)
+	msc, err := NewMultiScaleCleaner(Options{NumScales: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msc.imageSize = len(psf)
+	msc.setPSF(psf)
+
+	b64 := multiScaleBytes[float64](t, msc, dirty)
+	b32 := multiScaleBytes[float32](t, msc, dirty)
+	ratio := float64(b32) / float64(b64)
+	t.Logf("beams and residuals use %d bytes in float64 and %d in float32 (ratio %.2f)", b64, b32, ratio)
+	if ratio > 0.55 {
+		t.Errorf("float32 beams and residuals use %.2f of the float64 memory, want about half", ratio)
+	}
+}
@@ -0,0 +1,95 @@
+package clean
+
+import "math"
+
+// StopReason records why a deconvolver stopped iterating.
+type StopReason int
+
+const (
+	StopMaxIterations StopReason = iota
+	StopThreshold
+	StopNegativeComponent
+	StopNoise
+	StopFluxConverged
+	StopDiverging
+	StopTimeLimit
+	StopCancelled
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopMaxIterations:
+		return "maximum iterations reached"
+	case StopThreshold:
+		return "peak below threshold"
+	case StopNegativeComponent:
+		return "negative component found"
+	case StopNoise:
+		return "peak below noise threshold"
+	case StopFluxConverged:
+		return "model flux converged"
+	case StopDiverging:
+		return "residual RMS rising"
+	case StopTimeLimit:
+		return "time limit reached"
+	case StopCancelled:
+		return "cancelled"
+	}
+	return "unknown"
+}
+
+// Statistics summarises a CLEAN run.
+type Statistics struct {
+	Iterations   int
+	ModelFlux    float64
+	PeakResidual float64
+	ResidualRMS  float64
+}
+
+// CleanResult holds every product of a CLEAN run. ScaleResiduals is only set
+// by multi-scale deconvolvers and holds the residual smoothed with each scale.
+// The built-in deconvolvers fill in Stats; Restored and Beam are filled in by
+// Restore, and WCS by CleanImage.
+type CleanResult struct {
+	Model          Image
+	Restored       Image
+	Residual       Image
+	ScaleResiduals PFS
+	Components     []Component
+	Beam           Beam
+	WCS            WCS
+	Stats          Statistics
+	StopReason     StopReason
+}
+
+// Restore convolves the model with beam, adds the residual and records both
+// the beam and the run statistics in the result, so that Stats is also set
+// for deconvolvers that leave it empty.
+func (r *CleanResult) Restore(beam Beam) {
+	r.Beam = beam
+	r.Restored = Restore(r.Model, r.Residual, beam)
+	r.Stats = computeStatistics(r)
+}
+
+func computeStatistics(r *CleanResult) Statistics {
+	var stats Statistics
+	for _, c := range r.Components {
+		stats.ModelFlux += c.Flux
+		if c.Iteration > 0 {
+			stats.Iterations++
+		}
+	}
+	sumSq := 0.0
+	n := 0
+	for _, row := range r.Residual {
+		for _, val := range row {
+			stats.PeakResidual = math.Max(stats.PeakResidual, math.Abs(val))
+			sumSq += val * val
+			n++
+		}
+	}
+	if n > 0 {
+		stats.ResidualRMS = math.Sqrt(sumSq / float64(n))
+	}
+	return stats
+}
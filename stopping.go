@@ -0,0 +1,155 @@
+package clean
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	// measureInterval is how many components are subtracted between
+	// measurements of the residual noise and RMS.
+	measureInterval = 10
+	// convergenceWindow is the number of components over which the change
+	// in model flux is compared with FluxTolerance.
+	convergenceWindow = 10
+	// divergencePatience is how many consecutive rises of the residual RMS
+	// count as divergence.
+	divergencePatience = 3
+)
+
+// stopper evaluates the stopping criteria shared by all deconvolvers.
+type stopper struct {
+	opts  Options
+	start time.Time
+	noise float64
+	rms   float64
+	rises int
+	flux  []float64
+	// values is scratch space for the median of the residual, allocated
+	// only when a criterion needs the noise.
+	values []float64
+}
+
+func newStopper[T Float](opts Options, residual GridOf[T]) *stopper {
+	s := &stopper{
+		opts:  opts,
+		start: time.Now(),
+		rms:   math.Inf(1),
+		flux:  make([]float64, 0, opts.MaxIterations+1),
+	}
+	if s.needsNoise() {
+		s.values = make([]float64, 0, residual.Width*residual.Height)
+	}
+	measure(s, residual)
+	return s
+}
+
+// needsNoise reports whether a criterion uses the robust noise estimate,
+// which costs a sort of the residual.
+func (s *stopper) needsNoise() bool {
+	return s.opts.NoiseThreshold > 0 || s.opts.AutoMaskSigma > 0
+}
+
+// beforeComponent checks the peak about to be cleaned. The thresholds apply
+// to its magnitude when searching on absolute value and to its signed value
+// otherwise, so a negative peak ends a positive-peak search.
+func (s *stopper) beforeComponent(peak float64) (StopReason, bool) {
+	level := peak
+	if s.opts.AbsolutePeak {
+		level = math.Abs(peak)
+	}
+	switch {
+	case level < s.opts.Threshold:
+		return StopThreshold, true
+	case s.opts.NoiseThreshold > 0 && level < s.opts.NoiseThreshold*s.noise:
+		return StopNoise, true
+	case s.opts.PositiveOnly && peak < 0:
+		return StopNegativeComponent, true
+	case s.opts.TimeLimit > 0 && time.Since(s.start) > s.opts.TimeLimit:
+		return StopTimeLimit, true
+	case s.opts.Context != nil && s.opts.Context.Err() != nil:
+		return StopCancelled, true
+	}
+	return 0, false
+}
+
+// afterComponent records the flux of a component that has been cleaned and
+// checks whether the model flux has converged.
+func (s *stopper) afterComponent(flux float64) (StopReason, bool) {
+	total := flux
+	if n := len(s.flux); n > 0 {
+		total += s.flux[n-1]
+	}
+	s.flux = append(s.flux, total)
+	if s.opts.FluxTolerance > 0 && len(s.flux) > convergenceWindow {
+		change := total - s.flux[len(s.flux)-1-convergenceWindow]
+		if math.Abs(change) <= s.opts.FluxTolerance*math.Abs(total) {
+			return StopFluxConverged, true
+		}
+	}
+	return 0, false
+}
+
+// measure updates the robust noise estimate of s from residual, when a
+// criterion needs it, and checks whether the residual RMS keeps rising.
+func measure[T Float](s *stopper, residual GridOf[T]) (StopReason, bool) {
+	if s.needsNoise() {
+		s.noise = residualNoise(residual, s.values)
+	}
+	rms := residualRMS(residual)
+	if rms > s.rms {
+		s.rises++
+	} else {
+		s.rises = 0
+	}
+	s.rms = rms
+	if s.opts.StopOnDivergence && s.rises >= divergencePatience {
+		return StopDiverging, true
+	}
+	return 0, false
+}
+
+// residualRMS returns the root mean square of g.
+func residualRMS[T Float](g GridOf[T]) float64 {
+	sumSq := 0.0
+	for x := 0; x < g.Width; x++ {
+		for _, v := range g.Row(x) {
+			sumSq += float64(v) * float64(v)
+		}
+	}
+	n := g.Width * g.Height
+	if n == 0 {
+		return 0
+	}
+	return math.Sqrt(sumSq / float64(n))
+}
+
+// residualNoise returns the noise of g estimated from the median absolute
+// deviation, scaled to a Gaussian sigma. The pixels are sorted in buf, which
+// is grown if it is too small.
+func residualNoise[T Float](g GridOf[T], buf []float64) float64 {
+	values := buf[:0]
+	for x := 0; x < g.Width; x++ {
+		for _, v := range g.Row(x) {
+			values = append(values, float64(v))
+		}
+	}
+	if len(values) == 0 {
+		return 0
+	}
+	median := medianInPlace(values)
+	for i, val := range values {
+		values[i] = math.Abs(val - median)
+	}
+	return 1.4826 * medianInPlace(values)
+}
+
+func medianInPlace(values []float64) float64 {
+	slices.Sort(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
@@ -0,0 +1,211 @@
+package clean
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// pointSky returns point sources of the given fluxes on an n×n image,
+// convolved with a Gaussian PSF of unit peak.
+func pointSky(n int, fluxes map[Point]float64) (dirty, psf Image) {
+	sky := newImage(n, n)
+	for p, flux := range fluxes {
+		sky[p.x][p.y] = flux
+	}
+	psf = createPSFFromACB(n)
+	return convolveSame(sky, psf), psf
+}
+
+func TestPositiveOnlyAgainstAbsolutePeak(t *testing.T) {
+	dirty, psf := pointSky(32, map[Point]float64{{10, 12}: 1, {22, 20}: -0.6})
+	base := Options{MaxIterations: 200, Threshold: 1e-3, ScaleSizes: []float64{0, 2}}
+	for _, name := range Deconvolvers() {
+		d, err := NewDeconvolver(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		run := func(absolute, positive bool) *CleanResult {
+			opts := base
+			opts.AbsolutePeak, opts.PositiveOnly = absolute, positive
+			result, err := d.Deconvolve(dirty, psf, nil, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return result
+		}
+		negatives := func(r *CleanResult) int {
+			n := 0
+			for _, c := range r.Components {
+				if c.Flux < 0 {
+					n++
+				}
+			}
+			return n
+		}
+
+		signed := run(false, false)
+		if n := negatives(signed); n != 0 {
+			t.Errorf("%s: positive-peak search made %d negative components", name, n)
+		}
+		if r := run(false, true); len(r.Components) != len(signed.Components) {
+			t.Errorf("%s: positive-only changed a positive-peak search from %d to %d components",
+				name, len(signed.Components), len(r.Components))
+		}
+
+		absolute := run(true, false)
+		if negatives(absolute) == 0 {
+			t.Errorf("%s: absolute-peak search made no negative components", name)
+		}
+		positive := run(true, true)
+		if positive.StopReason != StopNegativeComponent {
+			t.Errorf("%s: positive-only absolute-peak search stopped with %v, want %v",
+				name, positive.StopReason, StopNegativeComponent)
+		}
+		if n := negatives(positive); n != 0 {
+			t.Errorf("%s: positive-only search made %d negative components", name, n)
+		}
+		// Both searches agree until the first negative peak.
+		if len(positive.Components) == 0 || len(positive.Components) >= len(absolute.Components) {
+			t.Fatalf("%s: positive-only search made %d components, absolute-peak search %d",
+				name, len(positive.Components), len(absolute.Components))
+		}
+		for i, c := range positive.Components {
+			if c != absolute.Components[i] {
+				t.Errorf("%s: component %d is %+v, absolute-peak search made %+v", name, i, c, absolute.Components[i])
+				break
+			}
+		}
+	}
+}
+
+func TestNegativeResidualNotCleanedByDefault(t *testing.T) {
+	dirty, psf := pointSky(32, map[Point]float64{{16, 16}: -1})
+	for _, name := range Deconvolvers() {
+		d, err := NewDeconvolver(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := d.Deconvolve(dirty, psf, nil, Options{MaxIterations: 20})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Components) != 0 || result.StopReason != StopThreshold {
+			t.Errorf("%s: made %d components and stopped with %v, want none and %v",
+				name, len(result.Components), result.StopReason, StopThreshold)
+		}
+	}
+}
+
+// stopReasons runs every deconvolver on dirty with opts and returns why each
+// stopped.
+func stopReasons(t *testing.T, dirty, psf Image, opts Options) map[string]*CleanResult {
+	t.Helper()
+	results := make(map[string]*CleanResult)
+	for _, name := range Deconvolvers() {
+		d, err := NewDeconvolver(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results[name], err = d.Deconvolve(dirty, psf, nil, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	return results
+}
+
+func expectStop(t *testing.T, results map[string]*CleanResult, want StopReason) {
+	t.Helper()
+	for name, r := range results {
+		if r.StopReason != want {
+			t.Errorf("%s: stopped with %v after %d components, want %v", name, r.StopReason, len(r.Components), want)
+		}
+	}
+}
+
+func TestStopOnNoise(t *testing.T) {
+	dirty, psf := pointSky(64, map[Point]float64{{30, 34}: 1})
+	rng := rand.New(rand.NewSource(7))
+	for _, row := range dirty {
+		for j := range row {
+			row[j] += 0.01 * rng.NormFloat64()
+		}
+	}
+	opts := Options{MaxIterations: 1000, NoiseThreshold: 5, ScaleSizes: []float64{0, 2}}
+	expectStop(t, stopReasons(t, dirty, psf, opts), StopNoise)
+}
+
+func TestStopOnFluxConvergence(t *testing.T) {
+	dirty, psf := pointSky(32, map[Point]float64{{16, 16}: 1})
+	opts := Options{MaxIterations: 1000, FluxTolerance: 0.01, Threshold: 1e-9, Set: SetThreshold}
+	expectStop(t, stopReasons(t, dirty, psf, opts), StopFluxConverged)
+}
+
+func TestStopOnDivergence(t *testing.T) {
+	// A PSF with a negative plateau adds flux to every other pixel for each
+	// component subtracted, so the residual RMS grows without bound.
+	n := 32
+	psf := newImage(n, n)
+	for _, row := range psf {
+		for j := range row {
+			row[j] = -0.05
+		}
+	}
+	psf[n/2][n/2] = 1
+	dirty := newImage(n, n)
+	dirty[n/2][n/2] = 1
+	opts := Options{MaxIterations: 1000, StopOnDivergence: true, ScaleSizes: []float64{0}}
+	results := stopReasons(t, dirty, psf, opts)
+	// Clark measures the RMS only between major cycles, and with no
+	// sidelobes outside its beam patch the first cycle runs to the end.
+	delete(results, "clark")
+	expectStop(t, results, StopDiverging)
+}
+
+func TestStopOnTimeLimit(t *testing.T) {
+	dirty, psf := pointSky(32, map[Point]float64{{16, 16}: 1})
+	opts := Options{MaxIterations: 1000, TimeLimit: time.Nanosecond}
+	expectStop(t, stopReasons(t, dirty, psf, opts), StopTimeLimit)
+}
+
+func TestStopOnCancel(t *testing.T) {
+	dirty, psf := pointSky(32, map[Point]float64{{16, 16}: 1})
+
+	// The point-source cleaners check the context before each component.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, name := range []string{"hogbom", "clark"} {
+		d, _ := NewDeconvolver(name)
+		r, err := d.Deconvolve(dirty, psf, nil, Options{Context: ctx})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if r.StopReason != StopCancelled || len(r.Components) != 0 {
+			t.Errorf("%s: stopped with %v after %d components, want %v after none", name, r.StopReason, len(r.Components), StopCancelled)
+		}
+	}
+
+	// Cancelling during the first residual update keeps that component.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	opts := Options{
+		MaxIterations: 100,
+		Workers:       1,
+		Context:       ctx,
+		TaskTimer: func(tt TaskTiming) {
+			if tt.Stage == "update residuals" {
+				cancel()
+			}
+		},
+	}
+	d, _ := NewDeconvolver("multiscale")
+	r, err := d.Deconvolve(dirty, psf, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StopReason != StopCancelled || len(r.Components) != 1 {
+		t.Errorf("multiscale: stopped with %v after %d components, want %v after one", r.StopReason, len(r.Components), StopCancelled)
+	}
+}
@@ -0,0 +1,44 @@
+package clean
+
+import "math"
+
+// supportRadius returns the half-widths in x and y of the smallest window
+// centred on the middle pixel of g that holds every pixel whose magnitude is
+// at least cutoff times the largest magnitude in g.
+func supportRadius[T Float](g GridOf[T], cutoff float64) (int, int) {
+	peak := 0.0
+	for x := 0; x < g.Width; x++ {
+		for _, v := range g.Row(x) {
+			peak = math.Max(peak, math.Abs(float64(v)))
+		}
+	}
+	level := cutoff * peak
+	cx, cy := g.Width/2, g.Height/2
+	rx, ry := 0, 0
+	for x := 0; x < g.Width; x++ {
+		for y, v := range g.Row(x) {
+			if math.Abs(float64(v)) >= level && v != 0 {
+				rx = max(rx, abs(x-cx))
+				ry = max(ry, abs(y-cy))
+			}
+		}
+	}
+	return rx, ry
+}
+
+// cropSupport returns a contiguous copy of the window of g given by
+// supportRadius. The middle pixel of g stays the middle pixel of the result,
+// so the cropped kernel can be used wherever g was. A cutoff of zero keeps
+// every non-zero pixel.
+func cropSupport[T Float](g GridOf[T], cutoff float64) GridOf[T] {
+	rx, ry := supportRadius(g, cutoff)
+	x0, y0 := g.Width/2-rx, g.Height/2-ry
+	width := min(2*rx+1, g.Width-x0)
+	height := min(2*ry+1, g.Height-y0)
+	return g.View(x0, y0, width, height).Clone()
+}
+
+// cropImageSupport is cropSupport for an Image.
+func cropImageSupport(img Image, cutoff float64) Image {
+	return cropSupport(GridFromImage(img), cutoff).Image()
+}
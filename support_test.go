@@ -0,0 +1,81 @@
+package clean
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCropSupportKeepsCentre(t *testing.T) {
+	for _, n := range []int{31, 32} {
+		g := NewGrid(n, n)
+		for x := 0; x < n; x++ {
+			for y := 0; y < n; y++ {
+				dx, dy := float64(x-n/2), float64(y-n/2)
+				g.Set(x, y, math.Exp(-(dx*dx/(2*1.5*1.5) + dy*dy/(2*3*3))))
+			}
+		}
+		c := cropSupport(g, 1e-3)
+		if c.Width >= n || c.Height >= n || c.Width > c.Height {
+			t.Errorf("n=%d: cropped to %dx%d", n, c.Width, c.Height)
+		}
+		if c.At(c.Width/2, c.Height/2) != 1 {
+			t.Errorf("n=%d: centre of cropped kernel is %g, want 1", n, c.At(c.Width/2, c.Height/2))
+		}
+		// Every pixel dropped by the crop is below the cutoff.
+		ox, oy := n/2-c.Width/2, n/2-c.Height/2
+		for x := 0; x < n; x++ {
+			for y := 0; y < n; y++ {
+				inside := x >= ox && x < ox+c.Width && y >= oy && y < oy+c.Height
+				if !inside && g.At(x, y) >= 1e-3 {
+					t.Fatalf("n=%d: dropped pixel (%d, %d) = %g", n, x, y, g.At(x, y))
+				}
+			}
+		}
+		if full := cropSupport(g, 0); full.Width != n || full.Height != n {
+			t.Errorf("n=%d: zero cutoff cropped to %dx%d", n, full.Width, full.Height)
+		}
+	}
+}
+
+func TestZeroSupportCutoffKeepsKernelsWhole(t *testing.T) {
+	hc, err := NewHogbomCleaner(Options{Set: SetSupportCutoff})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hc.opts.SupportCutoff != 0 {
+		t.Fatalf("support cutoff resolved to %g, want 0", hc.opts.SupportCutoff)
+	}
+	_, psf := syntheticSky(32)
+	hc.imageSize = len(psf)
+	hc.setPSF(psf)
+	// Only the zero border of the padded PSF is dropped.
+	whole := padCentered(hc.footprint, len(hc.psf), len(hc.psf[0]))
+	if d := maxAbsDiff(whole, hc.psf); d != 0 {
+		t.Errorf("%dx%d footprint differs from the PSF by %g", len(hc.footprint), len(hc.footprint[0]), d)
+	}
+}
+
+func TestSupportCutoffBarelyChangesResult(t *testing.T) {
+	dirty, psf := syntheticSky(64)
+	for _, name := range Deconvolvers() {
+		d, err := NewDeconvolver(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		run := func(cutoff float64) *CleanResult {
+			opts := Options{MaxIterations: 100, ScaleSizes: []float64{0, 2, 4}, SupportCutoff: cutoff, Set: SetSupportCutoff}
+			result, err := d.Deconvolve(dirty, psf, nil, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return result
+		}
+		whole := run(0)
+		for _, cutoff := range []float64{defaultSupportCutoff, 1e-4} {
+			got := run(cutoff)
+			if d := maxAbsDiff(got.Residual, whole.Residual) / maxValue(dirty); d > 1e-3 {
+				t.Errorf("%s: cutoff %g changes the residual by %g of the dirty peak", name, cutoff, d)
+			}
+		}
+	}
+}
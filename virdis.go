@@ -3,36 +3,93 @@
 // license that can be found in the LICENSE file..
 package clean
 
-import "image/color"
+import (
+	"image/color"
+	"math"
+)
 
+// Continuous is a colormap: ColorAt maps t in [0, 1] to a colour, clamping t
+// outside that range.
 type Continuous interface {
 	ColorAt(t float64) color.Color
 }
+
+// RGBGradient is a colormap through evenly spaced stops, interpolated
+// linearly in Space.
 type RGBGradient struct {
 	Colors []color.RGBA
+	Space  ColorSpace
 }
 
+// ColorAt returns the colour at t, rounded to the nearest 8-bit value. A
+// NaN t gives the first stop.
 func (g RGBGradient) ColorAt(t float64) color.Color {
-	if t <= 0 {
+	n := len(g.Colors)
+	if !(t > 0) || n == 1 {
 		return g.Colors[0]
 	}
 	if t >= 1 {
-		return g.Colors[len(g.Colors)-1]
+		return g.Colors[n-1]
 	}
 
-	pos := t * float64(len(g.Colors)-1)
-	idx := int(pos)
+	pos := t * float64(n-1)
+	idx := min(int(pos), n-2)
 	frac := pos - float64(idx)
 
 	c1 := g.Colors[idx]
 	c2 := g.Colors[idx+1]
+	if frac == 0 {
+		return c1
+	}
 
+	v1, v2 := g.Space.fromRGB(c1), g.Space.fromRGB(c2)
+	var v [3]float64
+	for i := range v {
+		v[i] = v1[i] + frac*(v2[i]-v1[i])
+	}
+	rgb := g.Space.toRGB(v)
 	return color.RGBA{
-		R: uint8(float64(c1.R) + frac*float64(c2.R-c1.R)),
-		G: uint8(float64(c1.G) + frac*float64(c2.G-c1.G)),
-		B: uint8(float64(c1.B) + frac*float64(c2.B-c1.B)),
-		A: uint8(float64(c1.A) + frac*float64(c2.A-c1.A)),
+		R: roundChannel(rgb[0]),
+		G: roundChannel(rgb[1]),
+		B: roundChannel(rgb[2]),
+		A: roundChannel(float64(c1.A) + frac*(float64(c2.A)-float64(c1.A))),
+	}
+}
+
+// roundChannel rounds v to the nearest value in 0-255.
+func roundChannel(v float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(255, v))))
+}
+
+// LUT is a colormap sampled at evenly spaced points, for colouring large
+// images without interpolating every pixel.
+type LUT []color.RGBA
+
+// NewLUT samples c at n >= 2 evenly spaced points from 0 to 1.
+func NewLUT(c Continuous, n int) LUT {
+	n = max(n, 2)
+	lut := make(LUT, n)
+	for i := range lut {
+		lut[i] = color.RGBAModel.Convert(c.ColorAt(float64(i) / float64(n-1))).(color.RGBA)
 	}
+	return lut
+}
+
+// At returns the entry nearest t, clamping t to [0, 1]. A NaN t gives the
+// first entry.
+func (l LUT) At(t float64) color.RGBA {
+	if !(t > 0) {
+		return l[0]
+	}
+	if t >= 1 {
+		return l[len(l)-1]
+	}
+	return l[int(t*float64(len(l)-1)+0.5)]
+}
+
+// ColorAt implements Continuous.
+func (l LUT) ColorAt(t float64) color.Color {
+	return l.At(t)
 }
 
 // Viridis is a continuous sequential color map that is perceptually
@@ -305,4 +362,6 @@ func init() {
 			{253, 231, 37, 255},
 		},
 	}
+
+	RegisterColormap("viridis", Viridis)
 }
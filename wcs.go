@@ -0,0 +1,351 @@
+package clean
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Angle is an angle in radians.
+type Angle float64
+
+const (
+	Radian         Angle = 1
+	Degree               = Radian * math.Pi / 180
+	Arcminute            = Degree / 60
+	Arcsecond            = Arcminute / 60
+	Milliarcsecond       = Arcsecond / 1000
+	Microarcsecond       = Milliarcsecond / 1000
+	hourAngle            = 15 * Degree
+)
+
+// angleUnits are the unit suffixes ParseAngle accepts, longest first so
+// that "mas" is not read as "as".
+var angleUnits = []struct {
+	suffix string
+	unit   Angle
+}{
+	{"arcsec", Arcsecond},
+	{"arcmin", Arcminute},
+	{"uas", Microarcsecond},
+	{"µas", Microarcsecond},
+	{"mas", Milliarcsecond},
+	{"deg", Degree},
+	{"rad", Radian},
+	{"as", Arcsecond},
+}
+
+// ParseAngle parses a number with an optional unit suffix: uas or µas, mas,
+// as or arcsec, arcmin, deg or rad. A bare number is in unit.
+func ParseAngle(s string, unit Angle) (Angle, error) {
+	s = strings.TrimSpace(s)
+	for _, u := range angleUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s, unit = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.unit
+			break
+		}
+	}
+	v, err := parseFinite(s)
+	if err != nil {
+		return 0, err
+	}
+	return Angle(v) * unit, nil
+}
+
+// Degrees returns a in degrees.
+func (a Angle) Degrees() float64 { return float64(a / Degree) }
+
+// Milliarcseconds returns a in milliarcseconds.
+func (a Angle) Milliarcseconds() float64 { return float64(a / Milliarcsecond) }
+
+// String formats a in the largest of µas, mas, arcsec and degrees in which
+// its magnitude is at least 1.
+func (a Angle) String() string {
+	v, unit := a.scaled()
+	return strconv.FormatFloat(v, 'g', 6, 64) + " " + unit
+}
+
+// scaled returns a in the unit String uses, and the unit's name.
+func (a Angle) scaled() (float64, string) {
+	switch m := math.Abs(float64(a)); {
+	case m >= float64(Degree):
+		return float64(a / Degree), "deg"
+	case m >= float64(Arcsecond):
+		return float64(a / Arcsecond), "arcsec"
+	case m >= float64(Milliarcsecond):
+		return float64(a / Milliarcsecond), "mas"
+	}
+	return float64(a / Microarcsecond), "µas"
+}
+
+// ParseRA parses a right ascension in hours as hh:mm:ss.s or in degrees as a
+// plain number.
+func ParseRA(s string) (Angle, error) {
+	if !strings.Contains(s, ":") {
+		return ParseAngle(s, Degree)
+	}
+	return parseSexagesimal(s, hourAngle)
+}
+
+// ParseDec parses a declination in degrees as ±dd:mm:ss.s or as a plain
+// number.
+func ParseDec(s string) (Angle, error) {
+	if !strings.Contains(s, ":") {
+		return ParseAngle(s, Degree)
+	}
+	return parseSexagesimal(s, Degree)
+}
+
+func parseSexagesimal(s string, unit Angle) (Angle, error) {
+	s = strings.TrimSpace(s)
+	sign := Angle(1)
+	if strings.HasPrefix(s, "-") {
+		sign, s = -1, s[1:]
+	} else {
+		s = strings.TrimPrefix(s, "+")
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("bad sexagesimal angle %q", s)
+	}
+	var a Angle
+	for i, p := range parts {
+		v, err := parseFinite(p)
+		if err != nil || v < 0 || (i > 0 && v >= 60) {
+			return 0, fmt.Errorf("bad sexagesimal angle %q", s)
+		}
+		a += Angle(v) * unit / Angle(math.Pow(60, float64(i)))
+	}
+	return sign * a, nil
+}
+
+// Projection is a FITS celestial projection.
+type Projection int
+
+const (
+	// SIN is the orthographic projection of aperture synthesis images.
+	SIN Projection = iota
+	// TAN is the gnomonic projection.
+	TAN
+)
+
+func (p Projection) String() string {
+	switch p {
+	case SIN:
+		return "SIN"
+	case TAN:
+		return "TAN"
+	}
+	return fmt.Sprintf("Projection(%d)", int(p))
+}
+
+// ParseProjection parses a FITS projection code such as SIN.
+func ParseProjection(s string) (Projection, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "SIN":
+		return SIN, nil
+	case "TAN":
+		return TAN, nil
+	}
+	return 0, fmt.Errorf("unsupported projection %q, want SIN or TAN", s)
+}
+
+// WCS places an image on the sky. Pixel (RefX, RefY), counted from 0, is
+// the phase centre (RA, Dec); x increases to the west and y to the north,
+// each pixel spanning Cell.
+type WCS struct {
+	Projection Projection
+	RefX, RefY float64
+	Cell       Angle
+	RA, Dec    Angle
+}
+
+// NewWCS returns a SIN projection of a width×height image centred on RA = Dec
+// = 0, with the phase centre at pixel (width/2, height/2) as the cleaners
+// assume.
+func NewWCS(width, height int, cell Angle) WCS {
+	return WCS{
+		Projection: SIN,
+		RefX:       float64(width / 2),
+		RefY:       float64(height / 2),
+		Cell:       cell,
+	}
+}
+
+// Validate reports whether w has a positive cell size and a phase centre on
+// the sky.
+func (w WCS) Validate() error {
+	switch {
+	case !(w.Cell > 0) || math.IsInf(float64(w.Cell), 0):
+		return fmt.Errorf("WCS cell size %v must be positive", w.Cell)
+	case math.IsNaN(float64(w.RA)) || math.IsInf(float64(w.RA), 0):
+		return fmt.Errorf("WCS RA %v must be finite", w.RA)
+	case !(math.Abs(float64(w.Dec)) <= math.Pi/2):
+		return fmt.Errorf("WCS Dec %v must be within ±90 deg", w.Dec)
+	case w.Projection != SIN && w.Projection != TAN:
+		return fmt.Errorf("unsupported projection %v", w.Projection)
+	}
+	return nil
+}
+
+// Offset returns the projected east and north offsets of pixel (x, y) from
+// the phase centre.
+func (w WCS) Offset(x, y float64) (east, north Angle) {
+	return Angle(w.RefX-x) * w.Cell, Angle(y-w.RefY) * w.Cell
+}
+
+// Pixel is the inverse of Offset.
+func (w WCS) Pixel(east, north Angle) (x, y float64) {
+	return w.RefX - float64(east/w.Cell), w.RefY + float64(north/w.Cell)
+}
+
+// PixelToSky returns the right ascension and declination of pixel (x, y).
+// It reports false for a SIN pixel outside the unit circle of the
+// projection.
+func (w WCS) PixelToSky(x, y float64) (ra, dec Angle, ok bool) {
+	east, north := w.Offset(x, y)
+	l, m := float64(east), float64(north)
+	var n float64
+	switch w.Projection {
+	case TAN:
+		n = 1
+	default:
+		r2 := l*l + m*m
+		if r2 > 1 {
+			return 0, 0, false
+		}
+		n = math.Sqrt(1 - r2)
+	}
+	e, u, c := w.basis()
+	var v [3]float64
+	for i := range v {
+		v[i] = l*e[i] + m*u[i] + n*c[i]
+	}
+	norm := math.Sqrt(v[0]*v[0] + v[1]*v[1] + v[2]*v[2])
+	ra = Angle(math.Atan2(v[1], v[0]))
+	if ra < 0 {
+		ra += 2 * math.Pi
+	}
+	return ra, Angle(math.Asin(v[2] / norm)), true
+}
+
+// SkyToPixel returns the pixel at right ascension ra and declination dec. It
+// reports false for a position on the far side of the projection.
+func (w WCS) SkyToPixel(ra, dec Angle) (x, y float64, ok bool) {
+	sinA, cosA := math.Sincos(float64(ra))
+	sinD, cosD := math.Sincos(float64(dec))
+	v := [3]float64{cosD * cosA, cosD * sinA, sinD}
+	e, u, c := w.basis()
+	l, m, n := dot(v, e), dot(v, u), dot(v, c)
+	switch w.Projection {
+	case TAN:
+		if n <= 0 {
+			return 0, 0, false
+		}
+		l, m = l/n, m/n
+	default:
+		if n < 0 {
+			return 0, 0, false
+		}
+	}
+	x, y = w.Pixel(Angle(l), Angle(m))
+	return x, y, true
+}
+
+// basis returns the unit vectors pointing east, north and to the phase
+// centre, in equatorial coordinates.
+func (w WCS) basis() (east, north, centre [3]float64) {
+	sinA, cosA := math.Sincos(float64(w.RA))
+	sinD, cosD := math.Sincos(float64(w.Dec))
+	east = [3]float64{-sinA, cosA, 0}
+	north = [3]float64{-sinD * cosA, -sinD * sinA, cosD}
+	centre = [3]float64{cosD * cosA, cosD * sinA, sinD}
+	return east, north, centre
+}
+
+func dot(a, b [3]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+// Tick is an axis tick at a round offset from the phase centre.
+type Tick struct {
+	Pixel  float64
+	Offset Angle
+	Label  string
+}
+
+// AxisLabel returns the label of axis 0 (x) or 1 (y) for ticks from Ticks,
+// such as "Relative RA (mas)".
+func (w WCS) AxisLabel(axis, size int) string {
+	_, unit := w.extent(size).scaled()
+	if axis == 0 {
+		return "Relative RA (" + unit + ")"
+	}
+	return "Relative Dec (" + unit + ")"
+}
+
+// Ticks returns at most maxTicks ticks at round offsets along axis 0 (x)
+// or 1 (y) of an image with size pixels on that axis. Labels are in the
+// unit of AxisLabel.
+func (w WCS) Ticks(axis, size, maxTicks int) []Tick {
+	if maxTicks < 2 || w.Cell <= 0 {
+		return nil
+	}
+	scale, _ := w.extent(size).scaled()
+	unit := w.extent(size) / Angle(scale)
+	span := float64(w.Cell/unit) * float64(size-1)
+	step := niceStep(span / float64(maxTicks-1))
+
+	// Round to the decimals of step, so labels do not show float noise.
+	round := math.Pow(10, math.Max(0, -math.Floor(math.Log10(step))))
+
+	var ticks []Tick
+	lo, hi := w.offsetRange(axis, size, unit)
+	for k := math.Ceil(lo / step); k*step <= hi+1e-9*step; k++ {
+		v := math.Round(k*step*round) / round
+		var px float64
+		if axis == 0 {
+			px, _ = w.Pixel(Angle(v)*unit, 0)
+		} else {
+			_, px = w.Pixel(0, Angle(v)*unit)
+		}
+		ticks = append(ticks, Tick{
+			Pixel:  px,
+			Offset: Angle(v) * unit,
+			Label:  strconv.FormatFloat(v+0, 'f', -1, 64),
+		})
+	}
+	return ticks
+}
+
+// extent returns the angle spanned by size pixels.
+func (w WCS) extent(size int) Angle {
+	return w.Cell * Angle(size)
+}
+
+// offsetRange returns the smallest and largest offset, in unit, of the
+// pixels along axis.
+func (w WCS) offsetRange(axis, size int, unit Angle) (float64, float64) {
+	var a, b Angle
+	if axis == 0 {
+		a, _ = w.Offset(0, 0)
+		b, _ = w.Offset(float64(size-1), 0)
+	} else {
+		_, a = w.Offset(0, 0)
+		_, b = w.Offset(0, float64(size-1))
+	}
+	lo, hi := float64(a/unit), float64(b/unit)
+	return math.Min(lo, hi), math.Max(lo, hi)
+}
+
+// niceStep rounds x up to 1, 2 or 5 times a power of ten.
+func niceStep(x float64) float64 {
+	p := math.Pow(10, math.Floor(math.Log10(x)))
+	for _, f := range []float64{1, 2, 5, 10} {
+		if f*p >= x {
+			return f * p
+		}
+	}
+	return 10 * p
+}
@@ -0,0 +1,95 @@
+package clean
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseAngle(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Angle
+	}{
+		{"20uas", 20 * Microarcsecond},
+		{"20 µas", 20 * Microarcsecond},
+		{"0.5mas", 0.5 * Milliarcsecond},
+		{"1e-3arcsec", Milliarcsecond},
+		{"2as", 2 * Arcsecond},
+		{"1.5", 1.5 * Milliarcsecond},
+	} {
+		got, err := ParseAngle(tc.in, Milliarcsecond)
+		if err != nil || math.Abs(float64(got-tc.want)) > 1e-9*float64(tc.want) {
+			t.Errorf("ParseAngle(%q) = %v, %v; want %v", tc.in, got, err, tc.want)
+		}
+	}
+	if _, err := ParseAngle("1 parsec", Milliarcsecond); err == nil {
+		t.Error("ParseAngle accepted an unknown unit")
+	}
+
+	// BL Lac: 22h02m43.29s +42d16m39.98s.
+	ra, err := ParseRA("22:02:43.29")
+	if err != nil || math.Abs(ra.Degrees()-330.680375) > 1e-9 {
+		t.Errorf("ParseRA = %v deg, %v", ra.Degrees(), err)
+	}
+	dec, err := ParseDec("+42:16:39.98")
+	if err != nil || math.Abs(dec.Degrees()-42.277772) > 1e-6 {
+		t.Errorf("ParseDec = %v deg, %v", dec.Degrees(), err)
+	}
+	if dec, _ := ParseDec("-0:30:00"); dec.Degrees() != -0.5 {
+		t.Errorf("ParseDec(-0:30:00) = %v deg, want -0.5", dec.Degrees())
+	}
+}
+
+func TestWCSRoundTrip(t *testing.T) {
+	for _, proj := range []Projection{SIN, TAN} {
+		for _, dec0 := range []float64{42.28, -89.9, 0} {
+			w := NewWCS(64, 64, 0.5*Arcsecond)
+			w.Projection = proj
+			w.RA, w.Dec = 330.68*Degree, Angle(dec0)*Degree
+			for _, p := range [][2]float64{{32, 32}, {0, 0}, {63, 10.5}, {-500, 900}} {
+				ra, dec, ok := w.PixelToSky(p[0], p[1])
+				if !ok {
+					t.Fatalf("%v at dec %g: pixel %v off the projection", proj, dec0, p)
+				}
+				x, y, ok := w.SkyToPixel(ra, dec)
+				if !ok || math.Abs(x-p[0]) > 1e-6 || math.Abs(y-p[1]) > 1e-6 {
+					t.Errorf("%v at dec %g: pixel %v -> (%v, %v) -> (%g, %g)", proj, dec0, p, ra, dec, x, y)
+				}
+			}
+		}
+	}
+
+	// At the phase centre one pixel west is one cell of RA to the west, so
+	// RA falls by cell/cos(dec).
+	w := NewWCS(64, 64, Arcsecond)
+	w.RA, w.Dec = 10*Degree, 60*Degree
+	if ra, dec, _ := w.PixelToSky(32, 32); math.Abs(float64(ra-w.RA)) > 1e-15 || math.Abs(float64(dec-w.Dec)) > 1e-15 {
+		t.Errorf("reference pixel at (%v, %v), want (%v, %v)", ra, dec, w.RA, w.Dec)
+	}
+	ra, _, _ := w.PixelToSky(33, 32)
+	if got := (w.RA - ra) / Arcsecond; math.Abs(float64(got)-2) > 1e-6 {
+		t.Errorf("one pixel west moves RA by %g arcsec, want 2", got)
+	}
+
+	if _, _, ok := NewWCS(4, 4, 90*Degree).PixelToSky(0, 0); ok {
+		t.Error("SIN pixel outside the unit circle converted")
+	}
+}
+
+func TestWCSTicks(t *testing.T) {
+	w := NewWCS(64, 64, 20*Microarcsecond)
+	if got := w.AxisLabel(0, 64); got != "Relative RA (mas)" {
+		t.Errorf("x axis label %q", got)
+	}
+	ticks := w.Ticks(0, 64, 5)
+	var labels []string
+	for _, tk := range ticks {
+		labels = append(labels, tk.Label)
+		if x, _ := w.Pixel(tk.Offset, 0); x != tk.Pixel || x < 0 || x > 63 {
+			t.Errorf("tick %q at pixel %g", tk.Label, tk.Pixel)
+		}
+	}
+	if len(labels) != 3 || labels[0] != "-0.5" || labels[1] != "0" || labels[2] != "0.5" {
+		t.Errorf("ticks %v, want [-0.5 0 0.5]", labels)
+	}
+}